@@ -0,0 +1,138 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"test-task/internal/audit"
+	"test-task/internal/logger"
+	models "test-task/internal/models"
+	"test-task/internal/storage"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxCacheCapacity keeps a mistyped resize from exhausting memory.
+	maxCacheCapacity = 100000
+
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type capacityRequest struct {
+	Capacity int `json:"capacity"`
+}
+
+func (a *App) CacheKeys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, a.repository.CacheKeys())
+}
+
+func (a *App) CacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, a.repository.CacheStats())
+}
+
+func (a *App) EvictCachedOrder(w http.ResponseWriter, r *http.Request) {
+	order_uid := mux.Vars(r)["order_uid"]
+	if !a.repository.EvictOrder(order_uid) {
+		a.audit.Record(r.Context(), models.AuditDelete, order_uid, audit.SourceHTTP, models.OutcomeNotFound)
+		http.Error(w, fmt.Sprintf("Order %v is not cached", order_uid), http.StatusNotFound)
+		return
+	}
+	a.audit.Record(r.Context(), models.AuditDelete, order_uid, audit.SourceHTTP, models.OutcomeSuccess)
+	logger.FromContext(r.Context()).Info("Order evicted from the cache", "order_uid", order_uid)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) FlushCache(w http.ResponseWriter, r *http.Request) {
+	a.repository.FlushCache()
+	logger.FromContext(r.Context()).Info("Cache flushed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) WarmCache(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	loaded, err := a.repository.WarmCache(r.Context())
+	if err != nil {
+		log.Error("Warming cache is failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Info("Cache warmed", "orders", loaded)
+	writeJSON(w, r, map[string]interface{}{"loaded": loaded})
+}
+
+func (a *App) ResizeCache(w http.ResponseWriter, r *http.Request) {
+	var req capacityRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Capacity < 1 || req.Capacity > maxCacheCapacity {
+		http.Error(w, fmt.Sprintf("capacity must be between 1 and %d", maxCacheCapacity), http.StatusBadRequest)
+		return
+	}
+
+	a.repository.ResizeCache(req.Capacity)
+	logger.FromContext(r.Context()).Info("Cache resized", "capacity", req.Capacity)
+	writeJSON(w, r, a.repository.CacheStats())
+}
+
+// Reencrypt moves every delivery to the primary encryption key, used after
+// adding a new primary key to the key file.
+func (a *App) Reencrypt(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	count, err := a.repository.ReencryptAll(r.Context())
+	if errors.Is(err, storage.ErrEncryptionDisabled) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Error("Re-encryption is failed", "error", err, "reencrypted", count)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Info("Deliveries re-encrypted", "count", count)
+	writeJSON(w, r, map[string]interface{}{"reencrypted": count})
+}
+
+// AuditLog returns the audit entries of an order or an actor, newest first.
+// Older pages are requested with before set to the smallest id received.
+func (a *App) AuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		OrderUID: query.Get("order_uid"),
+		Actor:    query.Get("actor"),
+		Limit:    defaultAuditLimit,
+	}
+	if filter.OrderUID == "" && filter.Actor == "" {
+		http.Error(w, "order_uid or actor is required", http.StatusBadRequest)
+		return
+	}
+	if value := query.Get("before"); value != "" {
+		before, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || before < 1 {
+			http.Error(w, "before must be a positive id", http.StatusBadRequest)
+			return
+		}
+		filter.Before = before
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := a.repository.GetAuditEntries(r.Context(), filter)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("Reading audit log is failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, entries)
+}
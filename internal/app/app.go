@@ -1,115 +1,587 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"test-task/internal/audit"
+	"test-task/internal/auth"
+	"test-task/internal/breaker"
 	cache "test-task/internal/cache"
+	"test-task/internal/encryption"
+	"test-task/internal/feed"
+	"test-task/internal/health"
+	"test-task/internal/httpserver"
+	"test-task/internal/invalidation"
 	"test-task/internal/kafka"
+	"test-task/internal/logger"
+	"test-task/internal/masking"
+	"test-task/internal/metrics"
 	models "test-task/internal/models"
+	"test-task/internal/retry"
 	"test-task/internal/storage"
+	"test-task/web"
 
-	"github.com/IBM/sarama"
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	statusEventsTopic = "order_status_events"
+
+	// Orders change over time, so clients must revalidate before reuse.
+	orderCacheControl = "private, no-cache"
+
+	// staleHeader marks orders served from the cache while the database
+	// is unavailable.
+	staleHeader       = "X-Stale"
+	retryAfterSeconds = 10
+
+	// redisCacheTTL expires shared entries that no instance refreshes.
+	redisCacheTTL = 10 * time.Minute
+
+	defaultListLimit = 20
+	maxListLimit     = 100
 )
 
 type App struct {
-	repository storage.Repository
+	repository Store
 	cache      cache.Cache
-	Producer   sarama.SyncProducer
-	Consumer   sarama.Consumer
+	Kafka      *kafka.Connection
+	Health     *health.Checker
+	// Invalidation keeps the caches of other instances consistent.
+	Invalidation *invalidation.Service
+	masking      *masking.Policy
+	audit        *audit.Recorder
+	// Web serves the UI files.
+	Web *httpserver.Static
+	// Feed streams the orders created by this instance.
+	Feed *feed.Broker
 }
 
-func NewApp(connStr string) (*App, error) {
-	app := &App{}
+type Config struct {
+	ConnStr string
+	Brokers []string
+	// KafkaSecurity is applied to every Kafka client.
+	KafkaSecurity kafka.Security
+	// Backoff is used for the initial connections and Kafka reconnects.
+	Backoff retry.Backoff
+	// CacheSnapshotPath is the cache snapshot file, empty disables snapshots.
+	CacheSnapshotPath string
+	// Redis is the shared second-level cache, an empty Addr disables it.
+	Redis cache.RedisOptions
+	// Masking hides personal data from HTTP callers by role, nil uses the
+	// default. Kafka replies are not masked, the topics are only open to
+	// trusted services, which have no role.
+	Masking *masking.Policy
+	// EncryptionKeyfile holds the keys of delivery encryption, empty stores
+	// personal data in plaintext.
+	EncryptionKeyfile string
+	// AuditTopic also publishes audit entries to Kafka, empty writes them to
+	// the database only.
+	AuditTopic string
+	// WebDir serves the UI from disk instead of the embedded copy, so pages
+	// can be edited without a rebuild. It is set by WEB_DEV_DIR, see the web
+	// package.
+	WebDir string
+}
 
-	err := app.repository.InitRepository(connStr)
-	if err != nil {
-		log.Printf("Unable to connect to database: %v", err)
-		return nil, err
+// NewApp connects to the database, retrying with backoff until ctx is done.
+// Kafka is connected in the background by Kafka.Run, until then the service
+// works in degraded mode serving reads only.
+func NewApp(ctx context.Context, config Config) (*App, error) {
+	app := &App{masking: config.Masking, Feed: feed.NewBroker()}
+	if app.masking == nil {
+		app.masking = masking.DefaultPolicy()
+	}
+	if config.WebDir != "" {
+		slog.Info("Serving the UI from disk", "dir", config.WebDir)
+		app.Web = httpserver.NewStatic(os.DirFS(config.WebDir), true)
+	} else {
+		app.Web = httpserver.NewStatic(web.Files, false)
 	}
 
-	brokers := []string{"localhost:9092"}
+	var backend *cache.Redis
+	options := storage.Options{
+		Backoff:      config.Backoff,
+		SnapshotPath: config.CacheSnapshotPath,
+	}
+	if config.EncryptionKeyfile != "" {
+		keyring, err := encryption.LoadKeyring(config.EncryptionKeyfile)
+		if err != nil {
+			slog.Error("Unable to load encryption keys", "error", err)
+			return nil, err
+		}
+		options.Keyring = keyring
+	}
 
-	producer, err := kafka.ConnectProducer(brokers)
-	if err != nil {
-		log.Printf("Unable to init Kafka producer: %v", err)
-		return nil, err
+	if config.Redis.Addr != "" {
+		if config.Redis.TTL == 0 {
+			config.Redis.TTL = redisCacheTTL
+		}
+		if options.Keyring != nil {
+			// Shared entries hold delivery personal data.
+			config.Redis.Sealer = options.Keyring
+		}
+		backend = cache.NewRedis(config.Redis)
+		options.Backend = backend
 	}
-	app.Producer = producer
 
-	consumer, err := kafka.ConnectConsumer(brokers)
+	repository := &storage.Repository{}
+	err := repository.InitRepository(ctx, config.ConnStr, options)
 	if err != nil {
-		log.Printf("Unable to init Kafka consumer: %v", err)
+		slog.Error("Unable to connect to database", "error", err)
 		return nil, err
 	}
-	app.Consumer = consumer
+
+	app.repository = repository
+
+	prometheus.MustRegister(
+		metrics.NewPoolCollector(repository.PoolStat),
+		metrics.NewCacheCollector(repository.CacheStats),
+	)
+
+	app.Kafka = kafka.NewConnection(config.Brokers, config.Backoff, config.KafkaSecurity)
+
+	var publish audit.Publisher
+	if config.AuditTopic != "" {
+		publish = func(ctx context.Context, entry models.AuditEntry) error {
+			return app.publish(ctx, entry, config.AuditTopic)
+		}
+	}
+	app.audit = audit.NewRecorder(repository, publish)
+
+	app.Invalidation = invalidation.NewService(invalidation.InstanceID(),
+		invalidation.KafkaTransport{Conn: app.Kafka}, repository)
+
+	app.Health = health.NewChecker()
+	app.Health.Add("postgres", repository.Ping)
+	app.Health.AddOptional("kafka", app.Kafka.Check)
+	if backend != nil {
+		app.Health.AddOptional("redis", backend.Ping)
+	}
+	app.Health.Add("cache", func(ctx context.Context) (string, error) {
+		if !repository.CacheWarmed() {
+			return "", errors.New("cache is not warmed")
+		}
+		return "", nil
+	})
 
 	return app, nil
 }
 
 func (a *App) HomeHandler(w http.ResponseWriter, r *http.Request) {
-	html, err := os.ReadFile("../web/index.html")
-	if err != nil {
-		log.Printf("Error reading index.html: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "internal error")
-		return
-	}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write(html)
+	a.Web.ServeFile(w, r, "index.html")
+}
+
+func (a *App) DocsHandler(w http.ResponseWriter, r *http.Request) {
+	a.Web.ServeFile(w, r, "swagger.html")
 }
 
 func (a *App) GetOrderById(w http.ResponseWriter, r *http.Request) {
 	order_uid := mux.Vars(r)["order_uid"]
-	log.Printf("Searching : %v", order_uid)
+	log := logger.FromContext(r.Context()).With("order_uid", order_uid)
+	log.Info("Searching order")
 
-	order, exist, err := a.repository.FindOrderById(order_uid)
+	order, exist, stale, err := a.repository.FindOrderById(r.Context(), order_uid)
 
-	if !exist {
-		fmt.Fprintf(w, "Order %v does not exist\n", order_uid)
+	if errors.Is(err, breaker.ErrOpen) {
+		a.audit.Record(r.Context(), models.AuditRead, order_uid, audit.SourceHTTP, models.OutcomeError)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+		http.Error(w, "database is unavailable", http.StatusServiceUnavailable)
 		return
 	} else if err != nil {
-		log.Printf("Finding order by id is failed: %v", err)
+		a.audit.Record(r.Context(), models.AuditRead, order_uid, audit.SourceHTTP, models.OutcomeError)
+		log.Error("Finding order by id is failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	} else if principal, _ := auth.FromContext(r.Context()); !exist || !principal.CanReadOrder(order.CustomerID) {
+		outcome := models.OutcomeNotFound
+		if exist {
+			outcome = models.OutcomeDenied
+		}
+		a.audit.Record(r.Context(), models.AuditRead, order_uid, audit.SourceHTTP, outcome)
+		// Orders of other customers look missing so their ids do not leak.
+		http.Error(w, fmt.Sprintf("Order %v does not exist", order_uid), http.StatusNotFound)
+		return
+	}
+	a.audit.Record(r.Context(), models.AuditRead, order_uid, audit.SourceHTTP, models.OutcomeSuccess)
+
+	etag := orderETag(order.Version)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", orderCacheControl)
+	// Masked fields depend on the caller.
+	w.Header().Set("Vary", "Authorization, X-API-Key")
+	if stale {
+		w.Header().Set(staleHeader, "true")
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	kafka.DoRequest(a.Producer, a.Consumer, order_uid, "get_order_by_id", "get_order_by_id_response")
+	if producer, consumer, err := a.Kafka.Clients(); err == nil {
+		// The handler records the read again, with the actor and the
+		// request id of this request.
+		kafka.DoRequest(r.Context(), producer, consumer, order_uid,
+			"get_order_by_id", "get_order_by_id_response")
+	}
 
-	json_data, err := json.MarshalIndent(order, "", "\t")
+	json_data, err := json.MarshalIndent(a.maskOrder(r.Context(), order), "", "\t")
 	if err != nil {
-		log.Printf("Failed to create json: %v", err)
+		log.Error("Failed to create json", "error", err)
 	}
+	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintf(w, "%s\n", json_data)
 }
 
-func (a *App) HandleGetOrderByID(uid string) (interface{}, error) {
+// ListOrders returns the summaries of recent orders, optionally with the
+// track number. Customers only see their own orders.
+func (a *App) ListOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.OrderFilter{
+		TrackNumber: strings.TrimSpace(query.Get("track_number")),
+		After:       query.Get("after"),
+		Limit:       defaultListLimit,
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	if principal, _ := auth.FromContext(r.Context()); principal.CustomerID != "" {
+		filter.CustomerID = principal.CustomerID
+	}
+
+	orders, err := a.repository.ListOrders(r.Context(), filter)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("Listing orders is failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	a.audit.RecordExport(r.Context(), audit.SourceHTTP, len(orders), url.Values{
+		"track_number": {filter.TrackNumber},
+		"customer_id":  {filter.CustomerID},
+		"after":        {filter.After},
+		"limit":        {strconv.Itoa(filter.Limit)},
+	})
+	writeJSON(w, r, orders)
+}
+
+// SearchOrders finds orders by the exact delivery phone or email.
+func (a *App) SearchOrders(w http.ResponseWriter, r *http.Request) {
+	phone := r.URL.Query().Get("phone")
+	email := r.URL.Query().Get("email")
+	if (phone == "") == (email == "") {
+		http.Error(w, "exactly one of phone or email is required", http.StatusBadRequest)
+		return
+	}
+	if phone != "" && encryption.NormalizePhone(phone) == "" {
+		http.Error(w, "phone has no digits", http.StatusBadRequest)
+		return
+	}
+
+	orders, err := a.repository.FindOrdersByContact(r.Context(), phone, email)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("Searching orders is failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	// The searched contact is personal data, only its kind is recorded.
+	by := "phone"
+	if email != "" {
+		by = "email"
+	}
+	a.audit.RecordExport(r.Context(), audit.SourceHTTP, len(orders), url.Values{"by": {by}})
+	for i := range orders {
+		orders[i] = a.maskOrder(r.Context(), orders[i])
+	}
+	writeJSON(w, r, orders)
+}
+
+// HandleGetOrderByID answers a Kafka lookup with the order unmasked, see
+// Config.Masking.
+func (a *App) HandleGetOrderByID(ctx context.Context, uid string) (interface{}, error) {
 	uid = strings.Trim(uid, `"`)
-	log.Printf("HandleSearching : %v", uid)
-	order, exist, err := a.repository.FindOrderById(uid)
+	log := logger.FromContext(ctx).With("order_uid", uid)
+	log.Info("Handle searching order")
+	source := audit.KafkaSource("get_order_by_id")
+	order, exist, _, err := a.repository.FindOrderById(ctx, uid)
 	if err != nil {
-		log.Printf("DB fetch error: %v", err)
+		a.audit.Record(ctx, models.AuditRead, uid, source, models.OutcomeError)
+		log.Error("DB fetch error", "error", err)
 		return nil, err
 	}
 	if !exist {
+		a.audit.Record(ctx, models.AuditRead, uid, source, models.OutcomeNotFound)
 		return nil, fmt.Errorf("Order %s is not found", uid)
 	}
+	a.audit.Record(ctx, models.AuditRead, uid, source, models.OutcomeSuccess)
 	return order, nil
 }
 
-func (a *App) HandleCreateOrders(data string) (interface{}, error) {
+func (a *App) HandleChangeOrderStatus(ctx context.Context, data string) (interface{}, error) {
+	var req models.StatusRequest
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		logger.FromContext(ctx).Error("Parse error", "error", err)
+		return nil, err
+	}
+	change, err := a.changeOrderStatus(ctx, req)
+	a.audit.Record(ctx, models.AuditUpdate, req.OrderUID, audit.KafkaSource("change_order_status"), auditOutcome(err))
+	return change, err
+}
+
+func (a *App) ChangeOrderStatus(w http.ResponseWriter, r *http.Request) {
+	var req models.StatusRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	req.OrderUID = mux.Vars(r)["order_uid"]
+
+	log := logger.FromContext(r.Context()).With("order_uid", req.OrderUID)
+	change, err := a.changeOrderStatus(r.Context(), req)
+	a.audit.Record(r.Context(), models.AuditUpdate, req.OrderUID, audit.SourceHTTP, auditOutcome(err))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrUnknownStatus):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, storage.ErrOrderNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, storage.ErrInvalidTransition):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Error("Changing status is failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(change); err != nil {
+		log.Error("Error while creating response", "error", err)
+	}
+}
+
+func (a *App) GetStatusHistory(w http.ResponseWriter, r *http.Request) {
+	order_uid := mux.Vars(r)["order_uid"]
+	log := logger.FromContext(r.Context()).With("order_uid", order_uid)
+
+	if principal, _ := auth.FromContext(r.Context()); principal.CustomerID != "" {
+		order, exist, _, err := a.repository.FindOrderById(r.Context(), order_uid)
+		if err != nil {
+			a.audit.Record(r.Context(), models.AuditRead, order_uid, audit.SourceHTTP, models.OutcomeError)
+			log.Error("Finding order by id is failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !exist || !principal.CanReadOrder(order.CustomerID) {
+			outcome := models.OutcomeNotFound
+			if exist {
+				outcome = models.OutcomeDenied
+			}
+			a.audit.Record(r.Context(), models.AuditRead, order_uid, audit.SourceHTTP, outcome)
+			http.Error(w, fmt.Sprintf("Order %v does not exist", order_uid), http.StatusNotFound)
+			return
+		}
+	}
+
+	history, err := a.repository.GetStatusHistory(r.Context(), order_uid)
+	if err != nil {
+		a.audit.Record(r.Context(), models.AuditRead, order_uid, audit.SourceHTTP, models.OutcomeError)
+		log.Error("Getting status history is failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if len(history) == 0 {
+		a.audit.Record(r.Context(), models.AuditRead, order_uid, audit.SourceHTTP, models.OutcomeNotFound)
+		http.Error(w, fmt.Sprintf("Order %v does not exist", order_uid), http.StatusNotFound)
+		return
+	}
+	a.audit.Record(r.Context(), models.AuditRead, order_uid, audit.SourceHTTP, models.OutcomeSuccess)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		log.Error("Error while creating response", "error", err)
+	}
+}
+
+// HandleUpdateOrder applies an update command from Kafka. The version is
+// optional there: commands without it are applied unconditionally.
+func (a *App) HandleUpdateOrder(ctx context.Context, data string) (interface{}, error) {
+	var update models.OrderUpdate
+	if err := json.Unmarshal([]byte(data), &update); err != nil {
+		logger.FromContext(ctx).Error("Parse error", "error", err)
+		return nil, err
+	}
+	order, err := a.updateOrder(ctx, update)
+	a.audit.Record(ctx, models.AuditUpdate, update.OrderUID, audit.KafkaSource("update_order"), auditOutcome(err))
+	return order, err
+}
+
+func (a *App) UpdateOrder(w http.ResponseWriter, r *http.Request) {
+	var update models.OrderUpdate
+	if !readJSON(w, r, &update) {
+		return
+	}
+	update.OrderUID = mux.Vars(r)["order_uid"]
+
+	// An update must name the version it is based on, through If-Match or
+	// the version field, so concurrent changes are not silently overwritten.
+	switch ifMatch := strings.TrimSpace(r.Header.Get("If-Match")); {
+	case ifMatch == "":
+		if update.Version == 0 {
+			http.Error(w, "If-Match header or version is required", http.StatusPreconditionRequired)
+			return
+		}
+	case ifMatch == "*":
+		// Any current version matches, the update is unconditional.
+		update.Version = 0
+	case strings.HasPrefix(ifMatch, "W/"):
+		// If-Match uses strong comparison, so a weak tag never matches.
+		http.Error(w, "weak ETag does not match", http.StatusPreconditionFailed)
+		return
+	default:
+		version, ok := parseETag(ifMatch)
+		if !ok {
+			http.Error(w, "invalid If-Match header", http.StatusBadRequest)
+			return
+		}
+		update.Version = version
+	}
+
+	log := logger.FromContext(r.Context()).With("order_uid", update.OrderUID)
+	order, err := a.updateOrder(r.Context(), update)
+	a.audit.Record(r.Context(), models.AuditUpdate, update.OrderUID, audit.SourceHTTP, auditOutcome(err))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrOrderNotFound), errors.Is(err, storage.ErrItemNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, storage.ErrVersionConflict):
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		default:
+			log.Error("Updating order is failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", orderETag(order.Version))
+	if err := json.NewEncoder(w).Encode(a.maskOrder(r.Context(), order)); err != nil {
+		log.Error("Error while creating response", "error", err)
+	}
+}
+
+// auditOutcome classifies the result of a mutation for the audit log.
+func auditOutcome(err error) models.AuditOutcome {
+	switch {
+	case err == nil:
+		return models.OutcomeSuccess
+	case errors.Is(err, storage.ErrOrderNotFound), errors.Is(err, storage.ErrItemNotFound):
+		return models.OutcomeNotFound
+	case errors.Is(err, models.ErrUnknownStatus), errors.Is(err, storage.ErrInvalidTransition),
+		errors.Is(err, storage.ErrVersionConflict):
+		return models.OutcomeRejected
+	default:
+		return models.OutcomeError
+	}
+}
+
+// orderETag builds a strong ETag from the order version.
+func orderETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header matches the ETag.
+// Weak comparison is used as required for If-None-Match.
+func etagMatches(header string, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func parseETag(etag string) (int, bool) {
+	version, err := strconv.Atoi(strings.Trim(etag, `"`))
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+func (a *App) changeOrderStatus(ctx context.Context, req models.StatusRequest) (models.StatusChange, error) {
+	status, err := models.ParseOrderStatus(string(req.Status))
+	if err != nil {
+		return models.StatusChange{}, err
+	}
+
+	change, err := a.repository.ChangeOrderStatus(ctx, req.OrderUID, status, req.Reason)
+	if err != nil {
+		return change, err
+	}
+	a.Invalidation.OrderChanged(ctx, change.OrderUID, 0)
+	a.publishStatusChange(ctx, change)
+	return change, nil
+}
+
+// publishStatusChange sends a status event. A failure is only logged, the
+// change is already stored.
+func (a *App) publishStatusChange(ctx context.Context, change models.StatusChange) {
+	if err := a.publish(ctx, change, statusEventsTopic); err != nil {
+		logger.FromContext(ctx).Error("Failed to publish status event", "order_uid", change.OrderUID, "error", err)
+	}
+}
+
+func (a *App) updateOrder(ctx context.Context, update models.OrderUpdate) (models.Order, error) {
+	order, err := a.repository.UpdateOrder(ctx, update)
+	if err != nil {
+		return order, err
+	}
+	a.Invalidation.OrderChanged(ctx, order.OrderUID, order.Version)
+	return order, nil
+}
+
+// maskOrder hides the fields the caller of the request may not see.
+func (a *App) maskOrder(ctx context.Context, order models.Order) models.Order {
+	principal, _ := auth.FromContext(ctx)
+	return a.masking.Apply(order, principal)
+}
+
+// publish sends an event to Kafka, failing while Kafka is not connected.
+func (a *App) publish(ctx context.Context, payload interface{}, topic string) error {
+	producer := a.Kafka.Producer()
+	if producer == nil {
+		return kafka.ErrNotConnected
+	}
+	return kafka.SendMessage(ctx, producer, payload, topic)
+}
+
+func (a *App) HandleCreateOrders(ctx context.Context, data string) (interface{}, error) {
+	log := logger.FromContext(ctx)
 	orderCount, err := strconv.Atoi(data)
 	if err != nil {
-		log.Printf("Parse error: %v", err)
+		log.Error("Parse error", "error", err)
 		return nil, err
 	}
 
@@ -124,10 +596,20 @@ func (a *App) HandleCreateOrders(data string) (interface{}, error) {
 			return nil, err
 		}
 
-		if err := a.repository.InsertToDB(&order); err != nil {
-			log.Printf("DB inserting error: %v", err)
+		if err := a.repository.InsertToDB(ctx, &order); err != nil {
+			a.audit.Record(ctx, models.AuditCreate, order.OrderUID, audit.KafkaSource("post_order"), models.OutcomeError)
+			log.Error("DB inserting error", "error", err)
 			return nil, err
 		}
+		a.audit.Record(ctx, models.AuditCreate, order.OrderUID, audit.KafkaSource("post_order"), models.OutcomeSuccess)
+		a.Invalidation.OrderChanged(ctx, order.OrderUID, order.Version)
+		// The first status of an order is an event like any later change.
+		a.publishStatusChange(ctx, models.StatusChange{
+			OrderUID:  order.OrderUID,
+			ToStatus:  order.Status,
+			ChangedAt: time.Now(),
+		})
+		a.Feed.Publish(order)
 		ordersAdded++
 		orders = append(orders, order)
 	}
@@ -136,24 +618,33 @@ func (a *App) HandleCreateOrders(data string) (interface{}, error) {
 }
 
 func (a *App) CreateOrders(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
 	orderCount := 2
-	msg := kafka.DoRequest(a.Producer, a.Consumer, orderCount,
+	producer, consumer, err := a.Kafka.Clients()
+	if err != nil {
+		http.Error(w, "order creation is unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	// The order service records the created orders with the actor of this
+	// request, also when its reply is lost.
+	msg := kafka.DoRequest(r.Context(), producer, consumer, orderCount,
 		"post_order", "post_order_response")
 
+	// Anything but an order list is an error reported by the order service
+	// or the Kafka request.
 	var orders []models.Order
 	if err := json.Unmarshal([]byte(msg), &orders); err != nil {
-		response := map[string]interface{}{
-			"error":   true,
-			"message": msg,
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(response)
+		log.Error("Order creation is failed", "reply", msg)
+		http.Error(w, "order creation is failed: "+msg, http.StatusBadGateway)
 		return
 	}
+	for i := range orders {
+		orders[i] = a.maskOrder(r.Context(), orders[i])
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(orders); err != nil {
-		log.Printf("Error while creating response: %v", err)
+		log.Error("Error while creating response", "error", err)
 	}
 }
 
@@ -202,12 +693,23 @@ func createRandomOrder(rng *rand.Rand) (models.Order, error) {
 	return order, nil
 }
 
+// RunCacheSnapshots periodically saves the cache snapshot until ctx is done.
+func (a *App) RunCacheSnapshots(ctx context.Context) {
+	a.repository.RunCacheSnapshots(ctx)
+}
+
+// RunReencryption moves delivery data to the primary key until ctx is done.
+func (a *App) RunReencryption(ctx context.Context) {
+	a.repository.RunReencryption(ctx)
+}
+
 func (a *App) Close() {
-	a.repository.Close()
-	if a.Producer != nil {
-		a.Producer.Close()
+	// The queued audit entries are written before the pool closes.
+	if a.audit != nil {
+		a.audit.Close()
 	}
-	if a.Consumer != nil {
-		a.Consumer.Close()
+	a.repository.Close()
+	if a.Kafka != nil {
+		a.Kafka.Close()
 	}
 }
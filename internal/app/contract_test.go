@@ -0,0 +1,515 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task/internal/audit"
+	"test-task/internal/auth"
+	"test-task/internal/cache"
+	"test-task/internal/feed"
+	"test-task/internal/health"
+	"test-task/internal/httpserver"
+	"test-task/internal/invalidation"
+	"test-task/internal/kafka"
+	"test-task/internal/masking"
+	models "test-task/internal/models"
+	"test-task/internal/openapi"
+	"test-task/internal/retry"
+	"test-task/internal/storage"
+	"test-task/web"
+
+	"github.com/gorilla/mux"
+)
+
+const testOrderUID = "b563feb7b2b84b6test"
+
+// fakeStore keeps orders in memory in place of the database.
+type fakeStore struct {
+	orders  map[string]models.Order
+	history map[string][]models.StatusChange
+}
+
+func newFakeStore() *fakeStore {
+	order := models.Order{
+		OrderUID:        testOrderUID,
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+		Status:          models.StatusCreated,
+		Version:         1,
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: testOrderUID, Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []models.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+	}
+	return &fakeStore{
+		orders:  map[string]models.Order{order.OrderUID: order},
+		history: map[string][]models.StatusChange{},
+	}
+}
+
+func (store *fakeStore) FindOrderById(ctx context.Context, order_uid string) (models.Order, bool, bool, error) {
+	order, exist := store.orders[order_uid]
+	return order, exist, false, nil
+}
+
+func (store *fakeStore) FindOrdersByContact(ctx context.Context, phone string, email string) ([]models.Order, error) {
+	orders := []models.Order{}
+	for _, order := range store.orders {
+		if order.Delivery.Phone == phone || order.Delivery.Email == email {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+func (store *fakeStore) ListOrders(ctx context.Context, filter models.OrderFilter) ([]models.OrderSummary, error) {
+	summaries := []models.OrderSummary{}
+	for _, order := range store.orders {
+		if filter.TrackNumber == "" || filter.TrackNumber == order.TrackNumber {
+			summaries = append(summaries, order.Summary())
+		}
+	}
+	return summaries, nil
+}
+
+func (store *fakeStore) InsertToDB(ctx context.Context, order *models.Order) error {
+	store.orders[order.OrderUID] = *order
+	return nil
+}
+
+func (store *fakeStore) UpdateOrder(ctx context.Context, update models.OrderUpdate) (models.Order, error) {
+	order, exist := store.orders[update.OrderUID]
+	if !exist {
+		return models.Order{}, storage.ErrOrderNotFound
+	}
+	if update.Version != 0 && update.Version != order.Version {
+		return models.Order{}, fmt.Errorf("%w: expected %d, current %d", storage.ErrVersionConflict, update.Version, order.Version)
+	}
+	if update.Empty() {
+		return order, nil
+	}
+	if update.Delivery != nil {
+		update.Delivery.Apply(&order.Delivery)
+	}
+	order.Version++
+	store.orders[order.OrderUID] = order
+	return order, nil
+}
+
+func (store *fakeStore) ChangeOrderStatus(ctx context.Context, order_uid string, status models.OrderStatus, reason string) (models.StatusChange, error) {
+	order, exist := store.orders[order_uid]
+	if !exist {
+		return models.StatusChange{}, storage.ErrOrderNotFound
+	}
+	change := models.StatusChange{
+		OrderUID: order_uid, FromStatus: order.Status, ToStatus: status, Reason: reason, ChangedAt: time.Now(),
+	}
+	order.Status = status
+	order.Version++
+	store.orders[order_uid] = order
+	store.history[order_uid] = append(store.history[order_uid], change)
+	return change, nil
+}
+
+func (store *fakeStore) GetStatusHistory(ctx context.Context, order_uid string) ([]models.StatusChange, error) {
+	return append([]models.StatusChange{}, store.history[order_uid]...), nil
+}
+
+func (store *fakeStore) GetAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	return []models.AuditEntry{{
+		ID: 1, Actor: auth.AnonymousSubject, Action: models.AuditRead, OrderUID: testOrderUID,
+		Source: audit.SourceHTTP, Outcome: models.OutcomeSuccess, Timestamp: time.Now(),
+	}}, nil
+}
+
+func (store *fakeStore) InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) error {
+	return nil
+}
+
+func (store *fakeStore) CacheStats() cache.Stats {
+	return cache.Stats{Capacity: 10, Size: len(store.orders)}
+}
+
+func (store *fakeStore) CacheKeys() []cache.KeyInfo {
+	return []cache.KeyInfo{{OrderUID: testOrderUID, Version: 1, AddedAt: time.Now(), Fresh: true}}
+}
+
+func (store *fakeStore) EvictOrder(order_uid string) bool {
+	_, exist := store.orders[order_uid]
+	return exist
+}
+
+func (store *fakeStore) FlushCache()                                {}
+func (store *fakeStore) ResizeCache(capacity int)                   {}
+func (store *fakeStore) WarmCache(ctx context.Context) (int, error) { return len(store.orders), nil }
+func (store *fakeStore) ReencryptAll(ctx context.Context) (int, error) {
+	return 0, storage.ErrEncryptionDisabled
+}
+func (store *fakeStore) RunReencryption(ctx context.Context)   {}
+func (store *fakeStore) RunCacheSnapshots(ctx context.Context) {}
+func (store *fakeStore) Close()                                {}
+
+type nopTransport struct{}
+
+func (nopTransport) Publish(ctx context.Context, event invalidation.Event) error { return nil }
+
+type nopCache struct{}
+
+func (nopCache) InvalidateOrder(ctx context.Context, order_uid string, version int) {}
+
+// newTestServer serves the routes of an app backed by fakeStore. Kafka is
+// never connected and requests run as an anonymous admin.
+func newTestServer(t *testing.T) (*httptest.Server, *App) {
+	t.Helper()
+
+	store := newFakeStore()
+	a := &App{
+		repository:   store,
+		Kafka:        kafka.NewConnection(nil, retry.Backoff{}, kafka.Security{}),
+		Health:       health.NewChecker(),
+		Invalidation: invalidation.NewService("test", nopTransport{}, nopCache{}),
+		masking:      masking.DefaultPolicy(),
+		audit:        audit.NewRecorder(store, nil),
+		Web:          httpserver.NewStatic(web.Files, false),
+		Feed:         feed.NewBroker(),
+	}
+
+	r := mux.NewRouter()
+	r.Use(auth.Middleware(auth.RoleAdmin))
+	a.Routes(r, func(group string, handler http.Handler) http.Handler {
+		return handler
+	}, func(group string, role auth.Role, handler http.HandlerFunc) http.Handler {
+		return auth.Require(role)(handler)
+	})
+
+	server := httptest.NewServer(r)
+	t.Cleanup(func() {
+		server.Close()
+		a.audit.Close()
+	})
+	return server, a
+}
+
+// specDocument returns the served OpenAPI document as decoded JSON.
+func specDocument(t *testing.T) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(openapi.Spec())
+	if err != nil {
+		t.Fatalf("marshal spec: %v", err)
+	}
+	var spec map[string]interface{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("unmarshal spec: %v", err)
+	}
+	return spec
+}
+
+func TestHandlersMatchSpec(t *testing.T) {
+	server, _ := newTestServer(t)
+	spec := specDocument(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		template string
+		header   map[string]string
+		body     string
+		status   int
+	}{
+		{"home page", "GET", "/", "/", nil, "", http.StatusOK},
+		{"order", "GET", "/order/" + testOrderUID, "/order/{order_uid}", nil, "", http.StatusOK},
+		{"order not modified", "GET", "/order/" + testOrderUID, "/order/{order_uid}",
+			map[string]string{"If-None-Match": `"1"`}, "", http.StatusNotModified},
+		{"missing order", "GET", "/order/missing", "/order/{order_uid}", nil, "", http.StatusNotFound},
+		{"update without precondition", "PATCH", "/order/" + testOrderUID, "/order/{order_uid}",
+			nil, `{"delivery":{"city":"Haifa"}}`, http.StatusPreconditionRequired},
+		{"update with weak tag", "PATCH", "/order/" + testOrderUID, "/order/{order_uid}",
+			map[string]string{"If-Match": `W/"1"`}, `{"delivery":{"city":"Haifa"}}`, http.StatusPreconditionFailed},
+		{"update with invalid body", "PATCH", "/order/" + testOrderUID, "/order/{order_uid}",
+			map[string]string{"If-Match": `"1"`}, `{`, http.StatusBadRequest},
+		{"update", "PATCH", "/order/" + testOrderUID, "/order/{order_uid}",
+			map[string]string{"If-Match": `"1"`}, `{"delivery":{"city":"Haifa"}}`, http.StatusOK},
+		{"update of stale version", "PATCH", "/order/" + testOrderUID, "/order/{order_uid}",
+			map[string]string{"If-Match": `"1"`}, `{"delivery":{"city":"Eilat"}}`, http.StatusPreconditionFailed},
+		{"update of any version", "PATCH", "/order/" + testOrderUID, "/order/{order_uid}",
+			map[string]string{"If-Match": "*"}, `{"delivery":{"city":"Eilat"}}`, http.StatusOK},
+		{"update of missing order", "PATCH", "/order/missing", "/order/{order_uid}",
+			map[string]string{"If-Match": "*"}, `{}`, http.StatusNotFound},
+		{"status history of missing order", "GET", "/order/missing/status", "/order/{order_uid}/status", nil, "", http.StatusNotFound},
+		{"status change", "POST", "/order/" + testOrderUID + "/status", "/order/{order_uid}/status",
+			nil, `{"status":"paid","reason":"test"}`, http.StatusOK},
+		{"status history", "GET", "/order/" + testOrderUID + "/status", "/order/{order_uid}/status", nil, "", http.StatusOK},
+		{"unknown status", "POST", "/order/" + testOrderUID + "/status", "/order/{order_uid}/status",
+			nil, `{"status":"lost"}`, http.StatusBadRequest},
+		{"order list", "GET", "/orders", "/orders", nil, "", http.StatusOK},
+		{"order list by track number", "GET", "/orders?track_number=WBILMTESTTRACK", "/orders", nil, "", http.StatusOK},
+		{"order list with invalid limit", "GET", "/orders?limit=0", "/orders", nil, "", http.StatusBadRequest},
+		{"search by phone", "GET", "/orders/search?phone=%2B9720000000", "/orders/search", nil, "", http.StatusOK},
+		{"search without contact", "GET", "/orders/search", "/orders/search", nil, "", http.StatusBadRequest},
+		{"create without Kafka", "GET", "/add", "/add", nil, "", http.StatusServiceUnavailable},
+		{"cache keys", "GET", "/admin/cache/keys", "/admin/cache/keys", nil, "", http.StatusOK},
+		{"cache flush", "DELETE", "/admin/cache/keys", "/admin/cache/keys", nil, "", http.StatusNoContent},
+		{"cache eviction", "DELETE", "/admin/cache/keys/" + testOrderUID, "/admin/cache/keys/{order_uid}", nil, "", http.StatusNoContent},
+		{"eviction of uncached order", "DELETE", "/admin/cache/keys/missing", "/admin/cache/keys/{order_uid}", nil, "", http.StatusNotFound},
+		{"cache stats", "GET", "/admin/cache/stats", "/admin/cache/stats", nil, "", http.StatusOK},
+		{"cache warm", "POST", "/admin/cache/warm", "/admin/cache/warm", nil, "", http.StatusOK},
+		{"cache resize", "PUT", "/admin/cache/capacity", "/admin/cache/capacity", nil, `{"capacity":20}`, http.StatusOK},
+		{"cache resize out of range", "PUT", "/admin/cache/capacity", "/admin/cache/capacity", nil, `{"capacity":0}`, http.StatusBadRequest},
+		{"reencryption without keys", "POST", "/admin/encryption/reencrypt", "/admin/encryption/reencrypt", nil, "", http.StatusConflict},
+		{"audit log", "GET", "/admin/audit?order_uid=" + testOrderUID, "/admin/audit", nil, "", http.StatusOK},
+		{"audit log without filter", "GET", "/admin/audit", "/admin/audit", nil, "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req, err := http.NewRequest(tt.method, server.URL+tt.url, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d, body %q", resp.StatusCode, tt.status, data)
+			}
+			checkResponse(t, spec, tt.method, tt.template, resp, data)
+		})
+	}
+}
+
+func TestFeedUnavailableMatchesSpec(t *testing.T) {
+	server, a := newTestServer(t)
+	spec := specDocument(t)
+	a.Feed.Close()
+
+	resp, err := http.Get(server.URL + "/orders/feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", resp.StatusCode)
+	}
+	checkResponse(t, spec, "GET", "/orders/feed", resp, data)
+}
+
+// TestRoutesAreDocumented checks that every API route is in the spec.
+func TestRoutesAreDocumented(t *testing.T) {
+	_, a := newTestServer(t)
+	spec := specDocument(t)
+	paths := spec["paths"].(map[string]interface{})
+
+	// Service endpoints and UI files are not part of the API.
+	undocumented := map[string]bool{
+		"/docs": true, "/static/": true, "/openapi.json": true, "/metrics": true,
+		"/healthz": true, "/readyz": true, "/status": true,
+	}
+
+	r := mux.NewRouter()
+	a.Routes(r, func(group string, handler http.Handler) http.Handler { return handler },
+		func(group string, role auth.Role, handler http.HandlerFunc) http.Handler { return handler })
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil || undocumented[template] {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		item, ok := paths[template].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s is not documented", template)
+			return nil
+		}
+		for _, method := range methods {
+			if method == "HEAD" {
+				continue
+			}
+			if _, ok := item[strings.ToLower(method)]; !ok {
+				t.Errorf("%s %s is not documented", method, template)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkResponse validates the status, content type, headers and body of a
+// response against the operation in the spec.
+func checkResponse(t *testing.T, spec map[string]interface{}, method string, template string, resp *http.Response, body []byte) {
+	t.Helper()
+
+	item, ok := spec["paths"].(map[string]interface{})[template].(map[string]interface{})
+	if !ok {
+		t.Fatalf("path %s is not in the spec", template)
+	}
+	operation, ok := item[strings.ToLower(method)].(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s %s is not in the spec", method, template)
+	}
+	response, ok := operation["responses"].(map[string]interface{})[strconv.Itoa(resp.StatusCode)].(map[string]interface{})
+	if !ok {
+		t.Fatalf("status %d of %s %s is not in the spec", resp.StatusCode, method, template)
+	}
+
+	if headers, ok := response["headers"].(map[string]interface{}); ok {
+		for _, name := range []string{"ETag", "Retry-After"} {
+			if _, documented := headers[name]; documented && resp.Header.Get(name) == "" {
+				t.Errorf("header %s is documented but missing", name)
+			}
+		}
+	}
+
+	content, ok := response["content"].(map[string]interface{})
+	if !ok {
+		if len(body) != 0 {
+			t.Errorf("status %d has no documented content, got %q", resp.StatusCode, body)
+		}
+		return
+	}
+	mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	media, ok := content[strings.TrimSpace(mediaType)].(map[string]interface{})
+	if !ok {
+		t.Fatalf("content type %q is not documented for status %d", mediaType, resp.StatusCode)
+	}
+	if mediaType != "application/json" {
+		return
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		t.Fatalf("invalid JSON body: %v", err)
+	}
+	for _, problem := range validateSchema(spec, media["schema"].(map[string]interface{}), value, "body") {
+		t.Error(problem)
+	}
+}
+
+// validateSchema checks a decoded JSON value against the subset of JSON
+// Schema the spec uses. Properties missing from the schema are reported so
+// undocumented fields do not slip into responses.
+func validateSchema(spec map[string]interface{}, schema map[string]interface{}, value interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		resolved, ok := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})[name].(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: unresolved %s", path, ref)}
+		}
+		return validateSchema(spec, resolved, value, path)
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if allowed == value {
+				found = true
+			}
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s: %v is not one of %v", path, value, enum)}
+		}
+	}
+
+	var problems []string
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: want object, got %T", path, value)}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required %s", path, name))
+			}
+		}
+		for name, field := range object {
+			property, ok := properties[name].(map[string]interface{})
+			if !ok {
+				if properties != nil {
+					problems = append(problems, fmt.Sprintf("%s: undocumented property %s", path, name))
+				}
+				continue
+			}
+			problems = append(problems, validateSchema(spec, property, field, path+"."+name)...)
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: want array, got %T", path, value)}
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		for i, element := range array {
+			problems = append(problems, validateSchema(spec, items, element, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: want string, got %T", path, value)}
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", path, text))
+			}
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return []string{fmt.Sprintf("%s: want integer, got %v", path, value)}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{fmt.Sprintf("%s: want number, got %T", path, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: want boolean, got %T", path, value)}
+		}
+	}
+	return problems
+}
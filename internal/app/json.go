@@ -0,0 +1,32 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"test-task/internal/logger"
+)
+
+// readJSON decodes the request body into v. On failure it writes 413 for
+// bodies over the server limit or 400 otherwise and returns false.
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "request body is too large", http.StatusRequestEntityTooLarge)
+	} else {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.FromContext(r.Context()).Error("Error while creating response", "error", err)
+	}
+}
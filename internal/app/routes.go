@@ -0,0 +1,53 @@
+package app
+
+import (
+	"net/http"
+
+	"test-task/internal/auth"
+	"test-task/internal/openapi"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Limit wraps a public handler with the rate limit of its route group.
+type Limit func(group string, handler http.Handler) http.Handler
+
+// Protect wraps an API handler with the rate limit of its route group and
+// the role the route requires.
+type Protect func(group string, role auth.Role, handler http.HandlerFunc) http.Handler
+
+// Routes registers the UI, the service endpoints and the API on r. The UI
+// and the docs are limited by the "public" group, the metrics and health
+// endpoints by the "probe" group.
+func (a *App) Routes(r *mux.Router, limit Limit, protect Protect) {
+	r.Handle("/", limit("public", http.HandlerFunc(a.HomeHandler))).Methods("GET", "HEAD")
+	r.Handle("/docs", limit("public", http.HandlerFunc(a.DocsHandler))).Methods("GET", "HEAD")
+	r.PathPrefix("/static/").Handler(limit("public", http.StripPrefix("/static/", a.Web))).Methods("GET", "HEAD")
+	r.Handle("/openapi.json", limit("public", http.HandlerFunc(openapi.Handler))).Methods("GET")
+	r.Handle("/metrics", limit("probe", promhttp.Handler())).Methods("GET")
+	r.Handle("/healthz", limit("probe", http.HandlerFunc(a.Health.Liveness))).Methods("GET")
+	r.Handle("/readyz", limit("probe", http.HandlerFunc(a.Health.Readiness))).Methods("GET")
+	r.Handle("/status", limit("probe", http.HandlerFunc(a.Health.Status))).Methods("GET")
+
+	r.Handle("/order/{order_uid}", protect("read", auth.RoleViewer, a.GetOrderById)).Methods("GET")
+	r.Handle("/order/{order_uid}", protect("write", auth.RoleOperator, a.UpdateOrder)).Methods("PATCH")
+	r.Handle("/order/{order_uid}/status", protect("read", auth.RoleViewer, a.GetStatusHistory)).Methods("GET")
+	r.Handle("/order/{order_uid}/status", protect("write", auth.RoleOperator, a.ChangeOrderStatus)).Methods("POST")
+	r.Handle("/orders", protect("read", auth.RoleViewer, a.ListOrders)).Methods("GET")
+	r.Handle("/orders/search", protect("read", auth.RoleOperator, a.SearchOrders)).Methods("GET")
+	r.Handle("/orders/feed", protect("read", auth.RoleViewer, a.Feed.ServeHTTP)).Methods("GET")
+	r.Handle("/add", protect("add", auth.RoleOperator, a.CreateOrders)).Methods("GET")
+
+	admin := func(handler http.HandlerFunc) http.Handler {
+		return protect("admin", auth.RoleAdmin, handler)
+	}
+	r.Handle("/admin/cache/keys", admin(a.CacheKeys)).Methods("GET")
+	r.Handle("/admin/cache/keys", admin(a.FlushCache)).Methods("DELETE")
+	r.Handle("/admin/cache/keys/{order_uid}", admin(a.EvictCachedOrder)).Methods("DELETE")
+	r.Handle("/admin/cache/stats", admin(a.CacheStats)).Methods("GET")
+	r.Handle("/admin/cache/warm", admin(a.WarmCache)).Methods("POST")
+	r.Handle("/admin/cache/capacity", admin(a.ResizeCache)).Methods("PUT")
+	r.Handle("/admin/encryption/reencrypt", admin(a.Reencrypt)).Methods("POST")
+	r.Handle("/admin/audit", admin(a.AuditLog)).Methods("GET")
+}
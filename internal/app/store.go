@@ -0,0 +1,34 @@
+package app
+
+import (
+	"context"
+
+	"test-task/internal/cache"
+	models "test-task/internal/models"
+)
+
+// Store is the order storage behind the handlers, implemented by
+// storage.Repository.
+type Store interface {
+	FindOrderById(ctx context.Context, order_uid string) (order models.Order, exist bool, stale bool, err error)
+	FindOrdersByContact(ctx context.Context, phone string, email string) ([]models.Order, error)
+	ListOrders(ctx context.Context, filter models.OrderFilter) ([]models.OrderSummary, error)
+	InsertToDB(ctx context.Context, order *models.Order) error
+	UpdateOrder(ctx context.Context, update models.OrderUpdate) (models.Order, error)
+	ChangeOrderStatus(ctx context.Context, order_uid string, status models.OrderStatus, reason string) (models.StatusChange, error)
+	GetStatusHistory(ctx context.Context, order_uid string) ([]models.StatusChange, error)
+	GetAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
+	InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) error
+
+	CacheStats() cache.Stats
+	CacheKeys() []cache.KeyInfo
+	EvictOrder(order_uid string) bool
+	FlushCache()
+	ResizeCache(capacity int)
+	WarmCache(ctx context.Context) (int, error)
+
+	ReencryptAll(ctx context.Context) (int, error)
+	RunReencryption(ctx context.Context)
+	RunCacheSnapshots(ctx context.Context)
+	Close()
+}
@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"test-task/internal/auth"
+	"test-task/internal/logger"
+	"test-task/internal/metrics"
+	models "test-task/internal/models"
+)
+
+const (
+	queueSize     = 4096
+	batchSize     = 256
+	flushInterval = time.Second
+	writeTimeout  = 5 * time.Second
+
+	// SourceHTTP is the source of entries recorded by HTTP handlers, Kafka
+	// handlers use KafkaSource of their topic.
+	SourceHTTP = "http"
+	// kafkaActor is recorded for Kafka commands sent without an actor.
+	kafkaActor = "kafka"
+)
+
+// Store appends entries to the durable audit log.
+type Store interface {
+	InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) error
+}
+
+// Publisher sends an entry to an optional stream such as a Kafka topic.
+type Publisher func(ctx context.Context, entry models.AuditEntry) error
+
+// Recorder writes audit entries in the background so recording does not add
+// a database round trip to every request. Entries are batched; if the queue
+// is full because the store is down, new entries are dropped and logged.
+type Recorder struct {
+	store   Store
+	publish Publisher
+
+	queue chan models.AuditEntry
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+// NewRecorder starts the writer. publish may be nil.
+func NewRecorder(store Store, publish Publisher) *Recorder {
+	recorder := &Recorder{
+		store:   store,
+		publish: publish,
+		queue:   make(chan models.AuditEntry, queueSize),
+		stop:    make(chan struct{}),
+	}
+	recorder.wg.Add(1)
+	go recorder.run()
+	return recorder
+}
+
+// KafkaSource is the source of entries recorded for a Kafka topic.
+func KafkaSource(topic string) string {
+	return "kafka:" + topic
+}
+
+// Record queues an entry. The actor is the principal of ctx, the actor
+// forwarded with a Kafka message, or kafkaActor when there is none. The
+// request id of ctx links the entries of one request recorded by different
+// handlers, such as an HTTP request and the Kafka command it sends.
+func (recorder *Recorder) Record(ctx context.Context, action models.AuditAction, order_uid string, source string, outcome models.AuditOutcome) {
+	recorder.enqueue(newEntry(ctx, action, order_uid, source, outcome))
+}
+
+// RecordExport queues one export entry for a list or a search, with the
+// number of returned orders and the filter in the details. Empty filter
+// values are left out.
+func (recorder *Recorder) RecordExport(ctx context.Context, source string, count int, filter url.Values) {
+	entry := newEntry(ctx, models.AuditExport, "", source, models.OutcomeSuccess)
+	details := url.Values{"count": {strconv.Itoa(count)}}
+	for key := range filter {
+		if value := filter.Get(key); value != "" {
+			details.Set(key, value)
+		}
+	}
+	entry.Details = details.Encode()
+	recorder.enqueue(entry)
+}
+
+func newEntry(ctx context.Context, action models.AuditAction, order_uid string, source string, outcome models.AuditOutcome) models.AuditEntry {
+	entry := models.AuditEntry{
+		Actor:     kafkaActor,
+		Action:    action,
+		OrderUID:  order_uid,
+		Source:    source,
+		Outcome:   outcome,
+		RequestID: logger.RequestID(ctx),
+		Timestamp: time.Now().UTC(),
+	}
+	if actor, ok := auth.Actor(ctx); ok {
+		entry.Actor = actor
+	}
+	return entry
+}
+
+func (recorder *Recorder) enqueue(entry models.AuditEntry) {
+	select {
+	case recorder.queue <- entry:
+	default:
+		metrics.AuditDropped(1)
+		slog.Error("Audit queue is full, entry dropped", "action", entry.Action, "order_uid", entry.OrderUID, "actor", entry.Actor)
+	}
+}
+
+// Close writes the queued entries and stops the writer.
+func (recorder *Recorder) Close() {
+	recorder.once.Do(func() {
+		close(recorder.stop)
+		recorder.wg.Wait()
+	})
+}
+
+func (recorder *Recorder) run() {
+	defer recorder.wg.Done()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.AuditEntry, 0, batchSize)
+	for {
+		select {
+		case entry := <-recorder.queue:
+			batch = append(batch, entry)
+			if len(batch) == batchSize {
+				batch = recorder.flush(batch)
+			}
+		case <-ticker.C:
+			batch = recorder.flush(batch)
+		case <-recorder.stop:
+			for {
+				select {
+				case entry := <-recorder.queue:
+					batch = append(batch, entry)
+				default:
+					recorder.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes the batch and returns it emptied. A failed batch is kept for
+// the next flush until the queue fills up.
+func (recorder *Recorder) flush(batch []models.AuditEntry) []models.AuditEntry {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	if err := recorder.store.InsertAuditEntries(ctx, batch); err != nil {
+		slog.Error("Unable to write audit entries", "count", len(batch), "error", err)
+		if len(batch) < queueSize {
+			return batch
+		}
+		metrics.AuditDropped(len(batch))
+		slog.Error("Audit entries dropped", "count", len(batch))
+		return batch[:0]
+	}
+
+	if recorder.publish != nil {
+		for _, entry := range batch {
+			if err := recorder.publish(ctx, entry); err != nil {
+				slog.Warn("Unable to publish audit entry", "order_uid", entry.OrderUID, "error", err)
+				break
+			}
+		}
+	}
+	return batch[:0]
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+
+	"test-task/internal/auth"
+	"test-task/internal/logger"
+	models "test-task/internal/models"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	entries []models.AuditEntry
+}
+
+func (store *memoryStore) InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.entries = append(store.entries, entries...)
+	return nil
+}
+
+func TestRecordActor(t *testing.T) {
+	store := &memoryStore{}
+	recorder := NewRecorder(store, nil)
+
+	ctx := logger.WithRequestID(context.Background(), "request-1")
+	recorder.Record(auth.WithPrincipal(ctx, auth.Principal{Subject: "user-1"}),
+		models.AuditRead, "order-1", SourceHTTP, models.OutcomeSuccess)
+	recorder.Record(auth.WithActor(ctx, "user-1"),
+		models.AuditRead, "order-1", KafkaSource("get_order_by_id"), models.OutcomeSuccess)
+	recorder.Record(context.Background(),
+		models.AuditCreate, "order-2", KafkaSource("post_order"), models.OutcomeSuccess)
+	recorder.Close()
+
+	want := []struct {
+		actor     string
+		source    string
+		requestID string
+	}{
+		{"user-1", SourceHTTP, "request-1"},
+		{"user-1", KafkaSource("get_order_by_id"), "request-1"},
+		{kafkaActor, KafkaSource("post_order"), ""},
+	}
+	if len(store.entries) != len(want) {
+		t.Fatalf("recorded %d entries, want %d: %+v", len(store.entries), len(want), store.entries)
+	}
+	for i, w := range want {
+		entry := store.entries[i]
+		if entry.Actor != w.actor || entry.Source != w.source || entry.RequestID != w.requestID {
+			t.Errorf("entry %d = %+v, want actor %s, source %s, request id %q", i, entry, w.actor, w.source, w.requestID)
+		}
+	}
+}
+
+func TestRecordExport(t *testing.T) {
+	store := &memoryStore{}
+	recorder := NewRecorder(store, nil)
+
+	recorder.RecordExport(context.Background(), SourceHTTP, 3, url.Values{
+		"track_number": {"WBIL123"},
+		"after":        {""},
+	})
+	recorder.Close()
+
+	if len(store.entries) != 1 {
+		t.Fatalf("recorded %d entries, want 1", len(store.entries))
+	}
+	entry := store.entries[0]
+	if entry.Action != models.AuditExport || entry.OrderUID != "" || entry.Details != "count=3&track_number=WBIL123" {
+		t.Errorf("entry = %+v", entry)
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// APIKeys authenticates static keys sent in the X-API-Key header or as a
+// bearer token. Keys are kept hashed so lookups do not leak timing.
+type APIKeys struct {
+	keys map[[sha256.Size]byte]Principal
+}
+
+func NewAPIKeys() *APIKeys {
+	return &APIKeys{keys: make(map[[sha256.Size]byte]Principal)}
+}
+
+func (apiKeys *APIKeys) Add(key string, principal Principal) {
+	apiKeys.keys[sha256.Sum256([]byte(key))] = principal
+}
+
+func (apiKeys *APIKeys) Len() int {
+	return len(apiKeys.keys)
+}
+
+// LoadAPIKeys reads a key file with one "<key> <role> [customer_id]" entry
+// per line. Empty lines and lines starting with # are skipped; the key is
+// also used as the subject, shortened to its first characters.
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	apiKeys := NewAPIKeys()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("%s:%d: expected \"<key> <role> [customer_id]\"", path, line)
+		}
+		role, err := ParseRole(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		principal := Principal{Subject: "key:" + keyPrefix(fields[0]), Role: role}
+		if len(fields) == 3 {
+			principal.CustomerID = fields[2]
+		}
+		apiKeys.Add(fields[0], principal)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
+// Authenticate never rejects an unknown bearer token, it may be a JWT.
+func (apiKeys *APIKeys) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		principal, ok := apiKeys.keys[sha256.Sum256([]byte(key))]
+		if !ok {
+			return Principal{}, fmt.Errorf("%w: unknown API key", ErrInvalidToken)
+		}
+		return principal, nil
+	}
+	if token, ok := bearerToken(r); ok {
+		if principal, ok := apiKeys.keys[sha256.Sum256([]byte(token))]; ok {
+			return principal, nil
+		}
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+func keyPrefix(key string) string {
+	if len(key) > 6 {
+		return key[:6]
+	}
+	return key
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Role grants access to a group of routes. Higher roles include lower ones.
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleOperator
+	RoleAdmin
+)
+
+// AnonymousSubject is the subject of requests without credentials.
+const AnonymousSubject = "anonymous"
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request has
+	// no credentials it understands, so the next one can be tried.
+	ErrNoCredentials = errors.New("no credentials")
+	ErrInvalidToken  = errors.New("invalid credentials")
+	ErrUnknownRole   = errors.New("unknown role")
+)
+
+func (role Role) String() string {
+	switch role {
+	case RoleViewer:
+		return "viewer"
+	case RoleOperator:
+		return "operator"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+func ParseRole(value string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "viewer":
+		return RoleViewer, nil
+	case "operator":
+		return RoleOperator, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("%w: %q", ErrUnknownRole, value)
+}
+
+// Principal is the authenticated caller.
+type Principal struct {
+	Subject string
+	Role    Role
+	// CustomerID limits the caller to orders of one customer. Customer
+	// scoped principals never get more than the viewer role.
+	CustomerID string
+}
+
+// CanReadOrder reports whether the caller may see an order of the customer.
+func (principal Principal) CanReadOrder(customerID string) bool {
+	return principal.CustomerID == "" || principal.CustomerID == customerID
+}
+
+// Authenticator extracts the principal from a request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal stored by Middleware.
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+type actorKey struct{}
+
+// WithActor stores the subject of a caller known only by name, such as the
+// sender of a Kafka message. The actor is not authenticated and grants no
+// role, it only names the caller in logs and the audit log.
+func WithActor(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, actorKey{}, subject)
+}
+
+// Actor returns the subject of the principal of ctx, or the actor stored by
+// WithActor.
+func Actor(ctx context.Context) (string, bool) {
+	if principal, ok := FromContext(ctx); ok {
+		return principal.Subject, true
+	}
+	subject, ok := ctx.Value(actorKey{}).(string)
+	return subject, ok && subject != ""
+}
+
+// Middleware authenticates requests with the first authenticator that finds
+// credentials. Requests with wrong credentials are rejected, requests without
+// credentials continue as anonymous with the given role, RoleNone makes
+// anonymous callers fail every Require check. A bearer token no
+// authenticator accepts is rejected rather than treated as anonymous.
+func Middleware(anonymous Role, authenticators ...Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := Principal{Subject: AnonymousSubject, Role: anonymous}
+			authenticated := false
+			for _, authenticator := range authenticators {
+				found, err := authenticator.Authenticate(r)
+				if errors.Is(err, ErrNoCredentials) {
+					continue
+				}
+				if err != nil {
+					slog.Warn("Authentication failed", "path", r.URL.Path, "error", err)
+					unauthorized(w)
+					return
+				}
+				principal, authenticated = found, true
+				break
+			}
+			if _, ok := bearerToken(r); ok && !authenticated {
+				slog.Warn("Authentication failed", "path", r.URL.Path, "error", "unknown bearer token")
+				unauthorized(w)
+				return
+			}
+			if principal.CustomerID != "" && principal.Role > RoleViewer {
+				principal.Role = RoleViewer
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// Require lets through callers with at least the given role.
+func Require(role Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := FromContext(r.Context())
+			switch {
+			case principal.Role == RoleNone:
+				unauthorized(w)
+			case principal.Role < role:
+				http.Error(w, "forbidden", http.StatusForbidden)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="orders"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// MiddlewareFromEnv builds Middleware from the environment:
+//
+//	AUTH_API_KEYS_FILE         static keys, see LoadAPIKeys
+//	ADMIN_TOKEN                an extra static key with the admin role
+//	JWT_HS256_SECRET_FILE      shared secret for HS256 tokens
+//	JWT_RS256_PUBLIC_KEY_FILE  PEM public key for RS256 tokens
+//	JWT_ISSUER, JWT_AUDIENCE   optional claims checked on tokens
+//	AUTH_ANONYMOUS_ROLE        role of requests without credentials
+func MiddlewareFromEnv() (func(http.Handler) http.Handler, error) {
+	var authenticators []Authenticator
+
+	apiKeys := NewAPIKeys()
+	if path := os.Getenv("AUTH_API_KEYS_FILE"); path != "" {
+		loaded, err := LoadAPIKeys(path)
+		if err != nil {
+			return nil, fmt.Errorf("load API keys: %w", err)
+		}
+		apiKeys = loaded
+	}
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		apiKeys.Add(token, Principal{Subject: "admin-token", Role: RoleAdmin})
+	}
+	if apiKeys.Len() > 0 {
+		authenticators = append(authenticators, apiKeys)
+	}
+
+	options := JWTOptions{Issuer: os.Getenv("JWT_ISSUER"), Audience: os.Getenv("JWT_AUDIENCE")}
+	if path := os.Getenv("JWT_HS256_SECRET_FILE"); path != "" {
+		verifier, err := NewHS256FromFile(path, options)
+		if err != nil {
+			return nil, fmt.Errorf("load JWT secret: %w", err)
+		}
+		authenticators = append(authenticators, verifier)
+	}
+	if path := os.Getenv("JWT_RS256_PUBLIC_KEY_FILE"); path != "" {
+		verifier, err := NewRS256FromFile(path, options)
+		if err != nil {
+			return nil, fmt.Errorf("load JWT public key: %w", err)
+		}
+		authenticators = append(authenticators, verifier)
+	}
+
+	anonymous := RoleNone
+	if value := os.Getenv("AUTH_ANONYMOUS_ROLE"); value != "" {
+		role, err := ParseRole(value)
+		if err != nil {
+			return nil, err
+		}
+		anonymous = role
+	}
+
+	if len(authenticators) == 0 && anonymous == RoleNone {
+		slog.Warn("No credentials are configured, protected routes reject every request")
+	}
+	return Middleware(anonymous, authenticators...), nil
+}
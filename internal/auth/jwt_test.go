@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func generateRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, c jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, c).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// TestJWTWithBothAlgorithms checks that configuring HS256 and RS256 together
+// accepts tokens of either algorithm.
+func TestJWTWithBothAlgorithms(t *testing.T) {
+	rsaKey := generateRSAKey(t)
+	publicKey, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs256, err := NewHS256FromFile(writeFile(t, "secret", []byte(testSecret)), JWTOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs256, err := NewRS256FromFile(writeFile(t, "public.pem",
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})), JWTOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Principal
+	handler := Middleware(RoleNone, hs256, rs256)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+	}))
+
+	valid := func(subject string, role string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": subject, "role": role, "exp": time.Now().Add(time.Hour).Unix()}
+	}
+	expired := jwt.MapClaims{"sub": "late", "exp": time.Now().Add(-time.Hour).Unix()}
+
+	tests := []struct {
+		name    string
+		token   string
+		status  int
+		subject string
+		role    Role
+	}{
+		{"HS256", sign(t, jwt.SigningMethodHS256, []byte(testSecret), valid("hs", "operator")),
+			http.StatusOK, "hs", RoleOperator},
+		{"RS256", sign(t, jwt.SigningMethodRS256, rsaKey, valid("rs", "admin")),
+			http.StatusOK, "rs", RoleAdmin},
+		{"HS256 with wrong secret", sign(t, jwt.SigningMethodHS256, []byte("another secret of at least 32 bytes"), valid("hs", "")),
+			http.StatusUnauthorized, "", RoleNone},
+		{"RS256 with wrong key", sign(t, jwt.SigningMethodRS256, generateRSAKey(t), valid("rs", "")),
+			http.StatusUnauthorized, "", RoleNone},
+		{"expired RS256", sign(t, jwt.SigningMethodRS256, rsaKey, expired),
+			http.StatusUnauthorized, "", RoleNone},
+		{"unconfigured algorithm", sign(t, jwt.SigningMethodHS512, []byte(testSecret), valid("hs", "")),
+			http.StatusUnauthorized, "", RoleNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = Principal{}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got.Subject != tt.subject || got.Role != tt.role {
+				t.Fatalf("principal = %+v, want subject %q role %v", got, tt.subject, tt.role)
+			}
+		})
+	}
+}
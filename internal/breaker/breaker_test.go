@@ -0,0 +1,105 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errFailed = errors.New("failed")
+
+func fail() error    { return errFailed }
+func succeed() error { return nil }
+
+func TestBreakerTransitions(t *testing.T) {
+	b := New(2, 20*time.Millisecond)
+
+	if err := b.Execute(fail); err != errFailed || b.State() != Closed {
+		t.Fatalf("after one failure: %v, %v, want the error and closed", err, b.State())
+	}
+	b.Execute(fail)
+	if b.State() != Open {
+		t.Fatalf("after the threshold state = %v, want open", b.State())
+	}
+
+	calls := 0
+	if err := b.Execute(func() error { calls++; return nil }); err != ErrOpen || calls != 0 {
+		t.Fatalf("open breaker = %v with %d calls, want ErrOpen without a call", err, calls)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if b.State() != HalfOpen {
+		t.Fatalf("after the open timeout state = %v, want half-open", b.State())
+	}
+
+	// The probe fails and opens the breaker again at once.
+	b.Execute(fail)
+	if b.State() != Open {
+		t.Fatalf("after a failed probe state = %v, want open", b.State())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := b.Execute(succeed); err != nil || b.State() != Closed {
+		t.Fatalf("after a successful probe: %v, %v, want closed", err, b.State())
+	}
+
+	// The failure count starts over after closing.
+	b.Execute(fail)
+	if b.State() != Closed {
+		t.Errorf("one failure after closing state = %v, want closed", b.State())
+	}
+}
+
+func TestBreakerLetsOneProbeThrough(t *testing.T) {
+	b := New(1, 10*time.Millisecond)
+	b.Execute(fail)
+	time.Sleep(20 * time.Millisecond)
+
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- b.Execute(func() error { <-release; return nil })
+	}()
+	// Wait until the probe is in flight.
+	for i := 0; i < 100; i++ {
+		b.mu.Lock()
+		probing := b.probing
+		b.mu.Unlock()
+		if probing {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := b.Execute(succeed); err != ErrOpen {
+		t.Errorf("second call during the probe = %v, want ErrOpen", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("probe = %v", err)
+	}
+}
+
+func TestBreakerPanicCountsAsFailure(t *testing.T) {
+	b := New(1, 10*time.Millisecond)
+	b.Execute(fail)
+	time.Sleep(20 * time.Millisecond)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic of the probe was swallowed")
+			}
+		}()
+		b.Execute(func() error { panic("probe failed") })
+	}()
+	if b.State() != Open {
+		t.Fatalf("after a panicked probe state = %v, want open", b.State())
+	}
+
+	// The breaker is not stuck waiting for the panicked probe.
+	time.Sleep(20 * time.Millisecond)
+	if err := b.Execute(succeed); err != nil || b.State() != Closed {
+		t.Errorf("after the next probe: %v, %v, want closed", err, b.State())
+	}
+}
@@ -2,58 +2,380 @@ package cache
 
 import (
 	"container/list"
-	"log"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
 
 	models "test-task/internal/models"
 )
 
-type Cache struct {
-	capacity  int
-	cacheMap  map[string]*list.Element
-	cacheList *list.List
+// negativeCapacity bounds the number of remembered missing orders.
+const negativeCapacity = 1000
+
+var errLoadPanicked = errors.New("order load panicked")
+
+// Cache is the order cache used by the repository. LRU is the in-process
+// implementation, Tiered puts a shared second-level backend behind it.
+type Cache interface {
+	Add(order *models.Order)
+	Get(order_uid string) (order *models.Order, exist bool, err error)
+	Lookup(order_uid string) (order *models.Order, fresh bool, exist bool)
+	GetOrLoad(order_uid string, load func() (*models.Order, error)) (order *models.Order, stale bool, err error)
+	Remove(order_uid string)
+	// RemoveLocal drops the order from this instance only, leaving the
+	// shared level of Tiered intact.
+	RemoveLocal(order_uid string)
+	Contains(order_uid string) bool
+	Peek(order_uid string) (*models.Order, bool)
+	Stats() Stats
+	Keys() []KeyInfo
+	Flush()
+	Resize(capacity int)
+}
+
+// Sealer encrypts orders written outside the process, to a shared backend
+// or a snapshot, so personal data does not leave it in plaintext. aad binds
+// a sealed value to its key. *encryption.Keyring implements it.
+type Sealer interface {
+	Seal(data []byte, aad string) ([]byte, error)
+	Open(sealed []byte, aad string) ([]byte, error)
+}
+
+// LRU is an in-process least recently used order cache.
+type LRU struct {
+	mu          sync.Mutex
+	capacity    int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	cacheMap    map[string]*list.Element
+	cacheList   *list.List
+	negative    map[string]time.Time
+	inflight    map[string]*call
+	stats       Stats
+	sealer      Sealer
+}
+
+// call is a load in progress that concurrent lookups of the same key wait for.
+type call struct {
+	wg    sync.WaitGroup
+	order *models.Order
+	stale bool
+	err   error
+}
+
+type entry struct {
+	order   *models.Order
+	addedAt time.Time
 }
 
-func CreateCache(capacity int) *Cache {
-	return &Cache{
+// Stats holds cache counters since creation and the current size.
+type Stats struct {
+	Hits         uint64 `json:"hits"`
+	StaleHits    uint64 `json:"stale_hits"`
+	NegativeHits uint64 `json:"negative_hits"`
+	Misses       uint64 `json:"misses"`
+	Coalesced    uint64 `json:"coalesced"`
+	Evictions    uint64 `json:"evictions"`
+	Size         int    `json:"size"`
+	Capacity     int    `json:"capacity"`
+
+	// Second-level counters, set only by Tiered.
+	L2Hits   uint64 `json:"l2_hits,omitempty"`
+	L2Misses uint64 `json:"l2_misses,omitempty"`
+	L2Errors uint64 `json:"l2_errors,omitempty"`
+}
+
+// KeyInfo describes a cached order for inspection.
+type KeyInfo struct {
+	OrderUID string    `json:"order_uid"`
+	Version  int       `json:"version"`
+	AddedAt  time.Time `json:"added_at"`
+	Fresh    bool      `json:"fresh"`
+}
+
+func CreateCache(capacity int) *LRU {
+	return &LRU{
 		capacity:  capacity,
 		cacheMap:  make(map[string]*list.Element),
 		cacheList: list.New(),
+		negative:  make(map[string]time.Time),
+		inflight:  make(map[string]*call),
 	}
 }
 
-func (cache *Cache) Add(order *models.Order) {
+// SetTTL sets how long entries stay fresh. Zero keeps them fresh forever.
+func (cache *LRU) SetTTL(ttl time.Duration) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.ttl = ttl
+}
+
+// SetNegativeTTL sets how long a missing order is remembered by GetOrLoad.
+// Zero disables negative caching.
+func (cache *LRU) SetNegativeTTL(ttl time.Duration) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.negativeTTL = ttl
+}
+
+// SetSealer encrypts the snapshots. Nil writes them in plaintext.
+func (cache *LRU) SetSealer(sealer Sealer) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.sealer = sealer
+}
+
+// Add caches the order. An order older than the cached version is ignored,
+// so a slow writer cannot replace a newer version.
+func (cache *LRU) Add(order *models.Order) {
+	cache.tryAdd(order)
+}
+
+// tryAdd adds the order and reports whether it was stored.
+func (cache *LRU) tryAdd(order *models.Order) bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.add(order)
+}
+
+// add reports whether the order was stored.
+func (cache *LRU) add(order *models.Order) bool {
+	delete(cache.negative, order.OrderUID)
 
 	if existingElement, exist := cache.cacheMap[order.OrderUID]; exist {
-		existingElement.Value = order
+		if existingElement.Value.(*entry).order.Version > order.Version {
+			return false
+		}
+		existingElement.Value = &entry{order: order, addedAt: time.Now()}
 		cache.cacheList.MoveToFront(existingElement)
-		return
+		return true
 	}
 
-	element := cache.cacheList.PushFront(order)
+	element := cache.cacheList.PushFront(&entry{order: order, addedAt: time.Now()})
 	cache.cacheMap[order.OrderUID] = element
-	log.Printf("Add order into cache: %v", order.OrderUID)
+	slog.Debug("Add order into cache", "order_uid", order.OrderUID)
 
 	if len(cache.cacheMap) > cache.capacity {
-		log.Printf("Remove oldest orders")
+		slog.Debug("Remove oldest orders")
 		cache.removeOldest()
 	}
+	return true
+}
+
+func (cache *LRU) Get(order_uid string) (order *models.Order, exist bool, err error) {
+	order, fresh, exist := cache.Lookup(order_uid)
+	if !fresh {
+		return nil, false, nil
+	}
+	return order, exist, nil
+}
+
+// Lookup returns the cached order even if it outlived the TTL, reporting
+// whether it is still fresh. Stale entries let callers fall back to old
+// data when the database is unavailable.
+func (cache *LRU) Lookup(order_uid string) (order *models.Order, fresh bool, exist bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.lookup(order_uid)
 }
 
-func (cache *Cache) Get(order_uid string) (order *models.Order, exist bool, err error) {
+func (cache *LRU) lookup(order_uid string) (order *models.Order, fresh bool, exist bool) {
 	element, exist := cache.cacheMap[order_uid]
 	if !exist {
-		return nil, false, err
+		cache.stats.Misses++
+		return nil, false, false
 	}
 
 	cache.cacheList.MoveToFront(element)
-	return element.Value.(*models.Order), true, nil
+	e := element.Value.(*entry)
+	if cache.ttl > 0 && time.Since(e.addedAt) > cache.ttl {
+		cache.stats.StaleHits++
+		return e.order, false, true
+	}
+	cache.stats.Hits++
+	return e.order, true, true
+}
+
+// GetOrLoad returns the cached order or calls load to fetch it. Concurrent
+// calls for the same key wait for a single load. A nil order from load means
+// the order does not exist; this is remembered for the negative TTL. If load
+// fails while an expired entry is cached, the expired entry is returned with
+// stale set.
+func (cache *LRU) GetOrLoad(order_uid string, load func() (*models.Order, error)) (order *models.Order, stale bool, err error) {
+	cache.mu.Lock()
+	if _, cached := cache.cacheMap[order_uid]; !cached && cache.isNegative(order_uid) {
+		cache.stats.NegativeHits++
+		cache.mu.Unlock()
+		return nil, false, nil
+	}
+	cached, fresh, exist := cache.lookup(order_uid)
+	if exist && fresh {
+		cache.mu.Unlock()
+		return cached, false, nil
+	}
+	if c, ok := cache.inflight[order_uid]; ok {
+		cache.stats.Coalesced++
+		cache.mu.Unlock()
+		c.wg.Wait()
+		return c.order, c.stale, c.err
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	cache.inflight[order_uid] = c
+	cache.mu.Unlock()
+
+	cache.load(order_uid, c, cached, exist, load)
+	return c.order, c.stale, c.err
+}
+
+// load runs the load of a call and settles it. The call is settled in a
+// defer so a panicking load does not leave waiters blocked and the key
+// stuck in flight; they get errLoadPanicked while the panic propagates.
+func (cache *LRU) load(order_uid string, c *call, cached *models.Order, exist bool, load func() (*models.Order, error)) {
+	c.err = errLoadPanicked
+	defer func() {
+		cache.mu.Lock()
+		delete(cache.inflight, order_uid)
+		switch {
+		case c.err != nil && exist:
+			slog.Warn("Serving stale order from the cache", "order_uid", order_uid, "error", c.err)
+			c.order, c.stale, c.err = cached, true, nil
+		case c.err != nil:
+		case c.order != nil:
+			cache.add(c.order)
+		default:
+			cache.remember(order_uid)
+		}
+		cache.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.order, c.err = load()
+}
+
+// Remove drops the order and any remembered miss for it.
+func (cache *LRU) Remove(order_uid string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	delete(cache.negative, order_uid)
+	if element, exist := cache.cacheMap[order_uid]; exist {
+		delete(cache.cacheMap, order_uid)
+		cache.cacheList.Remove(element)
+	}
+}
+
+// RemoveLocal is Remove, an LRU has no shared level.
+func (cache *LRU) RemoveLocal(order_uid string) {
+	cache.Remove(order_uid)
+}
+
+func (cache *LRU) isNegative(order_uid string) bool {
+	missedAt, ok := cache.negative[order_uid]
+	if !ok {
+		return false
+	}
+	if time.Since(missedAt) > cache.negativeTTL {
+		delete(cache.negative, order_uid)
+		return false
+	}
+	return true
+}
+
+func (cache *LRU) remember(order_uid string) {
+	if cache.negativeTTL <= 0 {
+		return
+	}
+	if len(cache.negative) >= negativeCapacity {
+		for uid, missedAt := range cache.negative {
+			if time.Since(missedAt) > cache.negativeTTL {
+				delete(cache.negative, uid)
+			}
+		}
+		if len(cache.negative) >= negativeCapacity {
+			cache.negative = make(map[string]time.Time)
+		}
+	}
+	cache.negative[order_uid] = time.Now()
+}
+
+// Contains reports whether the order is cached without touching its LRU position.
+func (cache *LRU) Contains(order_uid string) bool {
+	_, exist := cache.Peek(order_uid)
+	return exist
+}
+
+// Peek returns the cached order without touching its LRU position or stats.
+func (cache *LRU) Peek(order_uid string) (*models.Order, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, exist := cache.cacheMap[order_uid]
+	if !exist {
+		return nil, false
+	}
+	return element.Value.(*entry).order, true
+}
+
+func (cache *LRU) Stats() Stats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	stats := cache.stats
+	stats.Size = len(cache.cacheMap)
+	stats.Capacity = cache.capacity
+	return stats
+}
+
+// Keys lists the cached orders from the most to the least recently used
+// without touching their LRU position.
+func (cache *LRU) Keys() []KeyInfo {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	keys := make([]KeyInfo, 0, cache.cacheList.Len())
+	for element := cache.cacheList.Front(); element != nil; element = element.Next() {
+		e := element.Value.(*entry)
+		keys = append(keys, KeyInfo{
+			OrderUID: e.order.OrderUID,
+			Version:  e.order.Version,
+			AddedAt:  e.addedAt,
+			Fresh:    cache.ttl <= 0 || time.Since(e.addedAt) <= cache.ttl,
+		})
+	}
+	return keys
+}
+
+// Flush drops all cached orders and remembered misses.
+func (cache *LRU) Flush() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.cacheMap = make(map[string]*list.Element)
+	cache.cacheList.Init()
+	cache.negative = make(map[string]time.Time)
+}
+
+// Resize changes the capacity, evicting the least recently used orders
+// that no longer fit.
+func (cache *LRU) Resize(capacity int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.capacity = capacity
+	for len(cache.cacheMap) > cache.capacity {
+		cache.removeOldest()
+	}
 }
 
-func (cache *Cache) removeOldest() {
+func (cache *LRU) removeOldest() {
 
 	oldestElement := cache.cacheList.Back()
 	if oldestElement != nil {
-		delete(cache.cacheMap, oldestElement.Value.(*models.Order).OrderUID)
+		delete(cache.cacheMap, oldestElement.Value.(*entry).order.OrderUID)
 		cache.cacheList.Remove(oldestElement)
+		cache.stats.Evictions++
 	}
 }
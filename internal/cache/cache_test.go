@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	models "test-task/internal/models"
+)
+
+func TestGetOrLoadPanicReleasesKey(t *testing.T) {
+	cache := CreateCache(10)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic of load was swallowed")
+			}
+		}()
+		cache.GetOrLoad("a", func() (*models.Order, error) {
+			panic("load failed")
+		})
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		order, _, err := cache.GetOrLoad("a", func() (*models.Order, error) {
+			return &models.Order{OrderUID: "a"}, nil
+		})
+		if err != nil || order == nil {
+			t.Errorf("GetOrLoad after panic = %v, %v", order, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GetOrLoad is stuck behind the panicked load")
+	}
+}
+
+func TestGetOrLoadPanicFailsWaiters(t *testing.T) {
+	cache := CreateCache(10)
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { recover() }()
+		cache.GetOrLoad("a", func() (*models.Order, error) {
+			close(started)
+			<-release
+			panic("load failed")
+		})
+	}()
+	<-started
+
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := cache.GetOrLoad("a", func() (*models.Order, error) {
+			t.Error("waiter started its own load")
+			return nil, nil
+		})
+		result <- err
+	}()
+	for cache.Stats().Coalesced == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, errLoadPanicked) {
+			t.Fatalf("waiter error = %v, want %v", err, errLoadPanicked)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter is blocked after the panicked load")
+	}
+}
+
+func TestGetOrLoadNegativeHitIsNotMiss(t *testing.T) {
+	cache := CreateCache(10)
+	cache.SetNegativeTTL(time.Minute)
+
+	missing := func() (*models.Order, error) { return nil, nil }
+	cache.GetOrLoad("missing", missing)
+	cache.GetOrLoad("missing", missing)
+
+	stats := cache.Stats()
+	if stats.Misses != 1 || stats.NegativeHits != 1 {
+		t.Fatalf("misses = %d, negative hits = %d, want 1 and 1", stats.Misses, stats.NegativeHits)
+	}
+}
+
+func TestAddKeepsNewerVersion(t *testing.T) {
+	cache := CreateCache(10)
+	cache.Add(&models.Order{OrderUID: "a", Version: 3})
+	cache.Add(&models.Order{OrderUID: "a", Version: 2})
+
+	order, exist := cache.Peek("a")
+	if !exist || order.Version != 3 {
+		t.Fatalf("cached order = %+v, want version 3", order)
+	}
+
+	cache.Add(&models.Order{OrderUID: "a", Version: 4})
+	if order, _ := cache.Peek("a"); order.Version != 4 {
+		t.Errorf("cached version = %d, want 4", order.Version)
+	}
+}
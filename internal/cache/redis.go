@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	models "test-task/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces the keys and carries the encoding version, so a
+// change of the serialized form never reads entries written by old replicas.
+const redisKeyPrefix = "orders:v1:"
+
+// redisSealedKeyPrefix holds entries encrypted by a Sealer, so instances
+// with and without encryption never read each other's entries.
+const redisSealedKeyPrefix = redisKeyPrefix + "sealed:"
+
+// redisScanCount is the SCAN batch size used by Flush.
+const redisScanCount = 500
+
+// RedisOptions configure NewRedis.
+type RedisOptions struct {
+	Addr     string
+	Password string
+	DB       int
+	// TTL is the expiry set on every entry, zero keeps entries forever.
+	TTL time.Duration
+	// Sealer encrypts the entries, nil stores them in plaintext.
+	Sealer Sealer
+}
+
+// Redis is a Backend for any server speaking the Redis protocol.
+type Redis struct {
+	client *redis.Client
+	ttl    time.Duration
+	sealer Sealer
+	prefix string
+}
+
+func NewRedis(options RedisOptions) *Redis {
+	prefix := redisKeyPrefix
+	if options.Sealer != nil {
+		prefix = redisSealedKeyPrefix
+	}
+	return &Redis{
+		client: redis.NewClient(&redis.Options{
+			Addr:     options.Addr,
+			Password: options.Password,
+			DB:       options.DB,
+		}),
+		ttl:    options.TTL,
+		sealer: options.Sealer,
+		prefix: prefix,
+	}
+}
+
+func (backend *Redis) Get(ctx context.Context, order_uid string) (*models.Order, error) {
+	data, err := backend.client.Get(ctx, backend.prefix+order_uid).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if backend.sealer != nil {
+		if data, err = backend.sealer.Open(data, order_uid); err != nil {
+			return nil, fmt.Errorf("open order: %w", err)
+		}
+	}
+	return DecodeOrder(data)
+}
+
+func (backend *Redis) Set(ctx context.Context, order *models.Order) error {
+	data, err := EncodeOrder(order)
+	if err != nil {
+		return err
+	}
+	if backend.sealer != nil {
+		if data, err = backend.sealer.Seal(data, order.OrderUID); err != nil {
+			return fmt.Errorf("seal order: %w", err)
+		}
+	}
+	return backend.client.Set(ctx, backend.prefix+order.OrderUID, data, backend.ttl).Err()
+}
+
+func (backend *Redis) Delete(ctx context.Context, order_uid string) error {
+	return backend.client.Del(ctx, backend.prefix+order_uid).Err()
+}
+
+// Flush deletes the keys under the service prefix, leaving other data of a
+// shared server intact.
+func (backend *Redis) Flush(ctx context.Context) error {
+	iter := backend.client.Scan(ctx, 0, redisKeyPrefix+"*", redisScanCount).Iterator()
+	keys := make([]string, 0, redisScanCount)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == redisScanCount {
+			if err := backend.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return backend.client.Del(ctx, keys...).Err()
+	}
+	return nil
+}
+
+// Ping checks the connection and returns the server reply.
+func (backend *Redis) Ping(ctx context.Context) (string, error) {
+	return backend.client.Ping(ctx).Result()
+}
+
+func (backend *Redis) Close() error {
+	return backend.client.Close()
+}
+
+// EncodeOrder serializes an order for a shared backend.
+func EncodeOrder(order *models.Order) ([]byte, error) {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, fmt.Errorf("encode order: %w", err)
+	}
+	return data, nil
+}
+
+// DecodeOrder restores an order written by EncodeOrder, including the order
+// references of nested records that are not part of the JSON form.
+func DecodeOrder(data []byte) (*models.Order, error) {
+	var order models.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, fmt.Errorf("decode order: %w", err)
+	}
+	order.Delivery.OrderUID = order.OrderUID
+	order.Payment.OrderUID = order.OrderUID
+	for i := range order.Items {
+		order.Items[i].OrderUID = order.OrderUID
+	}
+	return &order, nil
+}
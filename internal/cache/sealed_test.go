@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"test-task/internal/encryption"
+	models "test-task/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+const testPhone = "+9720000000"
+
+func newTestKeyring(t *testing.T) *encryption.Keyring {
+	t.Helper()
+	key := func() string {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(raw)
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"primary":   "test",
+		"keys":      map[string]string{"test": key()},
+		"index_key": key(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := encryption.LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func testOrder() *models.Order {
+	return &models.Order{
+		OrderUID: "order-1",
+		Version:  1,
+		Delivery: models.Delivery{Name: "Test Testov", Phone: testPhone, Email: "test@gmail.com"},
+	}
+}
+
+func TestSealedSnapshot(t *testing.T) {
+	keyring := newTestKeyring(t)
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	source := CreateCache(10)
+	source.SetSealer(keyring)
+	source.Add(testOrder())
+	if err := source.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(testPhone)) {
+		t.Fatal("snapshot holds the phone in plaintext")
+	}
+
+	if _, err := CreateCache(10).LoadSnapshot(path, time.Hour); err == nil {
+		t.Fatal("sealed snapshot is loaded without keys")
+	}
+
+	restored := CreateCache(10)
+	restored.SetSealer(keyring)
+	if count, err := restored.LoadSnapshot(path, time.Hour); err != nil || count != 1 {
+		t.Fatalf("LoadSnapshot = %d, %v", count, err)
+	}
+	order, exist := restored.Peek("order-1")
+	if !exist || order.Delivery.Phone != testPhone {
+		t.Fatalf("restored order = %+v", order)
+	}
+}
+
+func TestSealedRedis(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	backend := NewRedis(RedisOptions{Addr: server.Addr(), Sealer: newTestKeyring(t)})
+	t.Cleanup(func() { backend.Close() })
+
+	if err := backend.Set(ctx, testOrder()); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range server.Keys() {
+		value, err := server.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains([]byte(value), []byte(testPhone)) {
+			t.Fatalf("%s holds the phone in plaintext", key)
+		}
+	}
+
+	order, err := backend.Get(ctx, "order-1")
+	if err != nil || order == nil || order.Delivery.Phone != testPhone {
+		t.Fatalf("Get = %+v, %v", order, err)
+	}
+
+	// A value moved to another key does not open.
+	server.Set(redisSealedKeyPrefix+"order-2", mustGet(t, server, redisSealedKeyPrefix+"order-1"))
+	if _, err := backend.Get(ctx, "order-2"); err == nil {
+		t.Fatal("entry of another order is accepted")
+	}
+}
+
+func mustGet(t *testing.T, server *miniredis.Miniredis, key string) string {
+	t.Helper()
+	value, err := server.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
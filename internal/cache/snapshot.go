@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	models "test-task/internal/models"
+)
+
+// Snapshot file layout: magic, format version (uint32), SHA-256 of the
+// payload, gob encoded payload. The sealed version encrypts the payload.
+const (
+	snapshotMagic         = "ORDCACHE"
+	snapshotVersion       = 1
+	snapshotSealedVersion = 2
+)
+
+var ErrSnapshotCorrupt = errors.New("cache snapshot is corrupt")
+
+type snapshot struct {
+	CreatedAt time.Time
+	// Entries are ordered from the most to the least recently used.
+	Entries []snapshotEntry
+}
+
+type snapshotEntry struct {
+	Order   models.Order
+	AddedAt time.Time
+}
+
+// SaveSnapshot writes the cache contents in LRU order to path, encrypted
+// when a sealer is set. The file is replaced atomically so a crash never
+// leaves a partial snapshot.
+func (cache *LRU) SaveSnapshot(path string) error {
+	cache.mu.Lock()
+	sealer := cache.sealer
+	snap := snapshot{
+		CreatedAt: time.Now(),
+		Entries:   make([]snapshotEntry, 0, cache.cacheList.Len()),
+	}
+	for element := cache.cacheList.Front(); element != nil; element = element.Next() {
+		e := element.Value.(*entry)
+		snap.Entries = append(snap.Entries, snapshotEntry{Order: *e.order, AddedAt: e.addedAt})
+	}
+	cache.mu.Unlock()
+
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(snap); err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	payload, version := buffer.Bytes(), uint32(snapshotVersion)
+	if sealer != nil {
+		sealed, err := sealer.Seal(payload, snapshotMagic)
+		if err != nil {
+			return fmt.Errorf("seal snapshot: %w", err)
+		}
+		payload, version = sealed, snapshotSealedVersion
+	}
+	sum := sha256.Sum256(payload)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	var header bytes.Buffer
+	header.WriteString(snapshotMagic)
+	binary.Write(&header, binary.BigEndian, version)
+	header.Write(sum[:])
+
+	if _, err := tmp.Write(header.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if _, err := tmp.Write(payload); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace snapshot: %w", err)
+	}
+
+	slog.Debug("Cache snapshot saved", "path", path, "orders", len(snap.Entries))
+	return nil
+}
+
+// LoadSnapshot restores the cache from path, keeping the LRU order and the
+// time entries were added. Snapshots of another format version, with a bad
+// checksum or older than maxAge are discarded, and so are sealed snapshots
+// when no sealer is set.
+func (cache *LRU) LoadSnapshot(path string, maxAge time.Duration) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	reader := bytes.NewReader(data)
+	magic := make([]byte, len(snapshotMagic))
+	var version uint32
+	var sum [sha256.Size]byte
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != snapshotMagic {
+		return 0, ErrSnapshotCorrupt
+	}
+	if err := binary.Read(reader, binary.BigEndian, &version); err != nil {
+		return 0, ErrSnapshotCorrupt
+	}
+	if version != snapshotVersion && version != snapshotSealedVersion {
+		return 0, fmt.Errorf("unsupported cache snapshot version %d", version)
+	}
+	if _, err := io.ReadFull(reader, sum[:]); err != nil {
+		return 0, ErrSnapshotCorrupt
+	}
+
+	payload := data[len(data)-reader.Len():]
+	if sha256.Sum256(payload) != sum {
+		return 0, ErrSnapshotCorrupt
+	}
+	if version == snapshotSealedVersion {
+		cache.mu.Lock()
+		sealer := cache.sealer
+		cache.mu.Unlock()
+		if sealer == nil {
+			return 0, errors.New("cache snapshot is encrypted and no keys are configured")
+		}
+		if payload, err = sealer.Open(payload, snapshotMagic); err != nil {
+			return 0, fmt.Errorf("open snapshot: %w", err)
+		}
+	}
+
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&snap); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	if maxAge > 0 && time.Since(snap.CreatedAt) > maxAge {
+		return 0, fmt.Errorf("cache snapshot is too old: created at %s", snap.CreatedAt.Format(time.RFC3339))
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// Push from the least recently used so the most recent ends up in front.
+	for i := len(snap.Entries) - 1; i >= 0; i-- {
+		e := snap.Entries[i]
+		order := e.Order
+		cache.add(&order)
+		if element, ok := cache.cacheMap[order.OrderUID]; ok {
+			element.Value.(*entry).addedAt = e.AddedAt
+		}
+	}
+	return len(snap.Entries), nil
+}
+
+// RunSnapshots saves a snapshot every interval until ctx is done.
+func (cache *LRU) RunSnapshots(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cache.SaveSnapshot(path); err != nil {
+				slog.Error("Unable to save cache snapshot", "path", path, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	models "test-task/internal/models"
+)
+
+// backendTimeout bounds every call to the second-level backend so a slow
+// backend degrades to a cache miss instead of stalling requests.
+const backendTimeout = 200 * time.Millisecond
+
+// flushTimeout bounds a backend flush, which walks all shared keys.
+const flushTimeout = 5 * time.Second
+
+// Backend is a second-level order cache shared between instances.
+type Backend interface {
+	// Get returns nil without an error when the order is not cached.
+	Get(ctx context.Context, order_uid string) (*models.Order, error)
+	Set(ctx context.Context, order *models.Order) error
+	Delete(ctx context.Context, order_uid string) error
+	// Flush deletes every order written by this service.
+	Flush(ctx context.Context) error
+	Ping(ctx context.Context) (string, error)
+	Close() error
+}
+
+// Tiered serves orders from the in-process LRU and falls back to the shared
+// backend before loading from the database. Writes go to both levels.
+// Backend failures are logged and treated as misses.
+type Tiered struct {
+	l1 *LRU
+	l2 Backend
+
+	l2Hits   atomic.Uint64
+	l2Misses atomic.Uint64
+	l2Errors atomic.Uint64
+}
+
+func NewTiered(l1 *LRU, l2 Backend) *Tiered {
+	return &Tiered{l1: l1, l2: l2}
+}
+
+// Add writes the order to both levels. An order older than the version in
+// the first level is not written to the shared one either.
+func (cache *Tiered) Add(order *models.Order) {
+	if cache.l1.tryAdd(order) {
+		cache.set(order)
+	}
+}
+
+func (cache *Tiered) Get(order_uid string) (*models.Order, bool, error) {
+	return cache.l1.Get(order_uid)
+}
+
+func (cache *Tiered) Lookup(order_uid string) (*models.Order, bool, bool) {
+	return cache.l1.Lookup(order_uid)
+}
+
+// GetOrLoad behaves like LRU.GetOrLoad, checking the backend before load
+// and storing loaded orders in it.
+func (cache *Tiered) GetOrLoad(order_uid string, load func() (*models.Order, error)) (*models.Order, bool, error) {
+	return cache.l1.GetOrLoad(order_uid, func() (*models.Order, error) {
+		if order := cache.get(order_uid); order != nil {
+			return order, nil
+		}
+		order, err := load()
+		if err == nil && order != nil {
+			cache.set(order)
+		}
+		return order, err
+	})
+}
+
+func (cache *Tiered) Remove(order_uid string) {
+	cache.l1.Remove(order_uid)
+
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+	if err := cache.l2.Delete(ctx, order_uid); err != nil {
+		cache.l2Errors.Add(1)
+		slog.Warn("Unable to delete order from the second-level cache", "order_uid", order_uid, "error", err)
+	}
+}
+
+// RemoveLocal drops the order from the first level only. Replicas use it on
+// invalidation, the instance that made the change has already updated the
+// shared level.
+func (cache *Tiered) RemoveLocal(order_uid string) {
+	cache.l1.Remove(order_uid)
+}
+
+func (cache *Tiered) Contains(order_uid string) bool {
+	return cache.l1.Contains(order_uid)
+}
+
+func (cache *Tiered) Peek(order_uid string) (*models.Order, bool) {
+	return cache.l1.Peek(order_uid)
+}
+
+func (cache *Tiered) Stats() Stats {
+	stats := cache.l1.Stats()
+	stats.L2Hits = cache.l2Hits.Load()
+	stats.L2Misses = cache.l2Misses.Load()
+	stats.L2Errors = cache.l2Errors.Load()
+	return stats
+}
+
+func (cache *Tiered) Keys() []KeyInfo {
+	return cache.l1.Keys()
+}
+
+// Flush empties both levels, so other instances miss the shared entries too.
+func (cache *Tiered) Flush() {
+	cache.l1.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	if err := cache.l2.Flush(ctx); err != nil {
+		cache.l2Errors.Add(1)
+		slog.Warn("Unable to flush the second-level cache", "error", err)
+	}
+}
+
+// Resize changes the capacity of the first level only.
+func (cache *Tiered) Resize(capacity int) {
+	cache.l1.Resize(capacity)
+}
+
+func (cache *Tiered) get(order_uid string) *models.Order {
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+
+	order, err := cache.l2.Get(ctx, order_uid)
+	switch {
+	case err != nil:
+		cache.l2Errors.Add(1)
+		slog.Warn("Unable to read order from the second-level cache", "order_uid", order_uid, "error", err)
+	case order == nil:
+		cache.l2Misses.Add(1)
+	default:
+		cache.l2Hits.Add(1)
+	}
+	return order
+}
+
+// set writes the order to the backend. When the write fails the shared entry
+// is deleted, so other instances do not keep serving the previous version.
+func (cache *Tiered) set(order *models.Order) {
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+	err := cache.l2.Set(ctx, order)
+	if err == nil {
+		return
+	}
+	cache.l2Errors.Add(1)
+	slog.Warn("Unable to write order to the second-level cache", "order_uid", order.OrderUID, "error", err)
+
+	// The write may have timed out, the delete gets a timeout of its own.
+	deleteCtx, cancelDelete := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancelDelete()
+	if err := cache.l2.Delete(deleteCtx, order.OrderUID); err != nil {
+		cache.l2Errors.Add(1)
+		slog.Warn("Unable to delete order from the second-level cache", "order_uid", order.OrderUID, "error", err)
+	}
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	models "test-task/internal/models"
+)
+
+// failingBackend keeps orders in a map and fails every Set.
+type failingBackend struct {
+	orders map[string]*models.Order
+}
+
+func (backend *failingBackend) Get(ctx context.Context, order_uid string) (*models.Order, error) {
+	return backend.orders[order_uid], nil
+}
+
+func (backend *failingBackend) Set(ctx context.Context, order *models.Order) error {
+	return errors.New("set failed")
+}
+
+func (backend *failingBackend) Delete(ctx context.Context, order_uid string) error {
+	delete(backend.orders, order_uid)
+	return nil
+}
+
+func (backend *failingBackend) Flush(ctx context.Context) error {
+	clear(backend.orders)
+	return nil
+}
+
+func (backend *failingBackend) Ping(ctx context.Context) (string, error) { return "", nil }
+
+func (backend *failingBackend) Close() error { return nil }
+
+func TestTieredFailedSetDropsSharedEntry(t *testing.T) {
+	backend := &failingBackend{orders: map[string]*models.Order{
+		"a": {OrderUID: "a", Version: 1},
+	}}
+	cache := NewTiered(CreateCache(10), backend)
+
+	cache.Add(&models.Order{OrderUID: "a", Version: 2})
+
+	if _, exist := backend.orders["a"]; exist {
+		t.Error("shared level kept the previous version")
+	}
+	if order, _ := cache.Peek("a"); order == nil || order.Version != 2 {
+		t.Errorf("local level = %+v, want version 2", order)
+	}
+	if stats := cache.Stats(); stats.L2Errors != 1 {
+		t.Errorf("L2Errors = %d, want 1", stats.L2Errors)
+	}
+}
@@ -0,0 +1,246 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// keySize is the size of every key: AES-256 and HMAC-SHA256.
+const keySize = 32
+
+// encryptedPrefix marks encrypted values so rows written before encryption
+// was enabled can still be read.
+const encryptedPrefix = "enc:v1:"
+
+var (
+	ErrUnknownKey = errors.New("unknown encryption key")
+	ErrDecrypt    = errors.New("unable to decrypt value")
+)
+
+// keyfile is the JSON layout of the key file. Keys are base64 encoded.
+//
+//	{
+//	  "primary": "2024-06",
+//	  "keys": {"2024-01": "...", "2024-06": "..."},
+//	  "index_key": "..."
+//	}
+type keyfile struct {
+	Primary  string            `json:"primary"`
+	Keys     map[string]string `json:"keys"`
+	IndexKey string            `json:"index_key"`
+}
+
+// Keyring holds the key encryption keys and the blind index key. New data
+// keys are wrapped with the primary key; older keys stay for decryption until
+// every row is re-encrypted. The index key cannot be rotated without
+// recomputing every blind index.
+type Keyring struct {
+	primary  string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// LoadKeyring reads the key file at path.
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keyfile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	keyring := &Keyring{primary: file.Primary, keys: make(map[string]cipher.AEAD)}
+	for id, encoded := range file.Keys {
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", path, id, err)
+		}
+		if keyring.keys[id], err = newAEAD(key); err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", path, id, err)
+		}
+	}
+	if _, ok := keyring.keys[keyring.primary]; !ok {
+		return nil, fmt.Errorf("%s: primary key %q: %w", path, keyring.primary, ErrUnknownKey)
+	}
+	if keyring.indexKey, err = decodeKey(file.IndexKey); err != nil {
+		return nil, fmt.Errorf("%s: index key: %w", path, err)
+	}
+	return keyring, nil
+}
+
+// PrimaryID is the id of the key new data keys are wrapped with.
+func (keyring *Keyring) PrimaryID() string {
+	return keyring.primary
+}
+
+// NewDataKey creates a random data key and returns it together with its
+// copy wrapped by the primary key.
+func (keyring *Keyring) NewDataKey() (dataKey []byte, wrapped []byte, keyID string, err error) {
+	dataKey = make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, "", err
+	}
+	wrapped, err = seal(keyring.keys[keyring.primary], dataKey, []byte(keyring.primary))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return dataKey, wrapped, keyring.primary, nil
+}
+
+// UnwrapDataKey decrypts a data key wrapped by the key with keyID.
+func (keyring *Keyring) UnwrapDataKey(keyID string, wrapped []byte) ([]byte, error) {
+	kek, ok := keyring.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	return open(kek, wrapped, []byte(keyID))
+}
+
+// sealedValue is a value encrypted directly with a key encryption key.
+type sealedValue struct {
+	KeyID string `json:"key_id"`
+	Data  []byte `json:"data"`
+}
+
+// Seal encrypts data kept outside the database, such as cache entries, with
+// the primary key. Unlike rows these values are short-lived, so they use no
+// data key and are not re-encrypted after a rotation.
+func (keyring *Keyring) Seal(data []byte, aad string) ([]byte, error) {
+	sealed, err := seal(keyring.keys[keyring.primary], data, []byte(keyring.primary+"/"+aad))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedValue{KeyID: keyring.primary, Data: sealed})
+}
+
+// Open decrypts a value written by Seal with the same aad.
+func (keyring *Keyring) Open(sealed []byte, aad string) ([]byte, error) {
+	var value sealedValue
+	if err := json.Unmarshal(sealed, &value); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	kek, ok := keyring.keys[value.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, value.KeyID)
+	}
+	return open(kek, value.Data, []byte(value.KeyID+"/"+aad))
+}
+
+// BlindIndex returns a keyed hash of the normalized value for exact match
+// lookups. kind separates the indexes of different fields.
+func (keyring *Keyring) BlindIndex(kind string, value string) []byte {
+	if value == "" {
+		return nil
+	}
+	mac := hmac.New(sha256.New, keyring.indexKey)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// Encrypt seals value with the data key. aad binds the ciphertext to its
+// row and column so it cannot be moved to another one.
+func Encrypt(dataKey []byte, value string, aad string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, []byte(value), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value written by Encrypt. Values without the encrypted
+// prefix are returned unchanged.
+func Decrypt(dataKey []byte, value string, aad string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(aead, sealed, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// NormalizePhone keeps only the digits so formatting does not affect lookups.
+func NormalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+}
+
+// NormalizeEmail lowercases the address and trims spaces.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns the random nonce followed by the ciphertext.
+func seal(aead cipher.AEAD, plain []byte, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,235 @@
+package feed
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"test-task/internal/auth"
+	"test-task/internal/logger"
+	models "test-task/internal/models"
+)
+
+const (
+	// historySize is how many events a reconnecting client can catch up on.
+	historySize = 500
+	// subscriberBuffer absorbs bursts; a client falling further behind is
+	// disconnected and catches up from the history when it reconnects.
+	subscriberBuffer = 64
+	maxSubscribers   = 1000
+
+	heartbeatInterval = 15 * time.Second
+	// retryMillis is the reconnect delay suggested to EventSource clients.
+	retryMillis = 3000
+)
+
+// Event is a newly persisted order.
+type Event struct {
+	ID    uint64
+	Order models.OrderSummary
+	// customerID is used for filtering only, it is not sent to clients.
+	customerID string
+}
+
+// Filter selects the events of a delivery service or a customer. Empty
+// fields match every order.
+type Filter struct {
+	DeliveryService string
+	CustomerID      string
+}
+
+func (filter Filter) matches(event Event) bool {
+	return (filter.DeliveryService == "" || filter.DeliveryService == event.Order.DeliveryService) &&
+		(filter.CustomerID == "" || filter.CustomerID == event.customerID)
+}
+
+type subscriber struct {
+	filter Filter
+	events chan Event
+}
+
+// Broker streams new orders to clients as Server-Sent Events. Orders created
+// by this instance are published, and a short history lets clients resume
+// from the Last-Event-ID after a reconnect.
+type Broker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		// Ids start from the startup time, so they keep growing across
+		// restarts and a client resuming after one is not seen as ahead.
+		nextID:      uint64(time.Now().UnixMilli()) * 1000,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Publish sends the order to the matching subscribers.
+func (broker *Broker) Publish(order models.Order) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	broker.nextID++
+	event := Event{ID: broker.nextID, Order: order.Summary(), customerID: order.CustomerID}
+	broker.history = append(broker.history, event)
+	if len(broker.history) > historySize {
+		broker.history = append(broker.history[:0], broker.history[len(broker.history)-historySize:]...)
+	}
+
+	for sub := range broker.subscribers {
+		if !sub.filter.matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(broker.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// subscribe registers a subscriber and returns the events after lastID it
+// has missed. The channel is closed when the subscriber falls behind or the
+// broker closes.
+func (broker *Broker) subscribe(filter Filter, lastID uint64) ([]Event, *subscriber, bool) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	if broker.closed || len(broker.subscribers) >= maxSubscribers {
+		return nil, nil, false
+	}
+
+	var missed []Event
+	if lastID > 0 {
+		for _, event := range broker.history {
+			if event.ID > lastID && filter.matches(event) {
+				missed = append(missed, event)
+			}
+		}
+	}
+	sub := &subscriber{filter: filter, events: make(chan Event, subscriberBuffer)}
+	broker.subscribers[sub] = struct{}{}
+	return missed, sub, true
+}
+
+func (broker *Broker) unsubscribe(sub *subscriber) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	if _, ok := broker.subscribers[sub]; ok {
+		delete(broker.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Close ends the open streams so server shutdown does not wait for them.
+func (broker *Broker) Close() {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	broker.closed = true
+	for sub := range broker.subscribers {
+		delete(broker.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// ServeHTTP streams the events filtered by the delivery_service and
+// customer_id query parameters. Customers only receive their own orders.
+// The stream resumes after the Last-Event-ID header, or the last_event_id
+// parameter for clients that cannot set headers.
+func (broker *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	filter := Filter{
+		DeliveryService: r.URL.Query().Get("delivery_service"),
+		CustomerID:      r.URL.Query().Get("customer_id"),
+	}
+	if principal, _ := auth.FromContext(r.Context()); principal.CustomerID != "" {
+		filter.CustomerID = principal.CustomerID
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	missed, sub, ok := broker.subscribe(filter, lastID)
+	if !ok {
+		http.Error(w, "feed is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	defer broker.unsubscribe(sub)
+
+	// The stream outlives the server write timeout.
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		log.Warn("Unable to clear the write deadline of the feed", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+	for _, event := range missed {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := controller.Flush(); err != nil {
+		log.Error("Streaming is not supported", "error", err)
+		return
+	}
+	log.Debug("Feed client connected", "filter", filter, "missed", len(missed))
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			// Comments keep proxies from closing an idle connection.
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event.Order)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: order\ndata: %s\n\n", event.ID, data)
+	return err
+}
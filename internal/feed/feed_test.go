@@ -0,0 +1,184 @@
+package feed
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task/internal/auth"
+	models "test-task/internal/models"
+)
+
+// startFeed serves the broker as the given principal.
+func startFeed(t *testing.T, broker *Broker, principal auth.Principal) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		broker.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}))
+	t.Cleanup(func() {
+		broker.Close()
+		server.Close()
+	})
+	return server
+}
+
+// connect opens the stream and returns its reader once the broker has
+// registered the client.
+func connect(t *testing.T, ctx context.Context, broker *Broker, url string, lastEventID string) *bufio.Reader {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("feed status = %d", resp.StatusCode)
+	}
+	waitSubscribers(t, broker, 1)
+	return bufio.NewReader(resp.Body)
+}
+
+func waitSubscribers(t *testing.T, broker *Broker, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		broker.mu.Lock()
+		count := len(broker.subscribers)
+		broker.mu.Unlock()
+		if count == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("broker has %d subscribers, want %d", count, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type received struct {
+	id    uint64
+	order models.OrderSummary
+}
+
+// readEvents reads n order events, skipping the retry field and comments.
+func readEvents(t *testing.T, reader *bufio.Reader, n int) []received {
+	t.Helper()
+	var events []received
+	var current received
+	for len(events) < n {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read after %d events: %v", len(events), err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			current.id, _ = strconv.ParseUint(strings.TrimPrefix(line, "id: "), 10, 64)
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.order); err != nil {
+				t.Fatal(err)
+			}
+		case line == "" && current.id != 0:
+			events = append(events, current)
+			current = received{}
+		}
+	}
+	return events
+}
+
+func order(uid string, customerID string) models.Order {
+	return models.Order{OrderUID: uid, CustomerID: customerID, DeliveryService: "meest"}
+}
+
+func TestFeedScopesCustomers(t *testing.T) {
+	broker := NewBroker()
+	customer := auth.Principal{Subject: "customer", Role: auth.RoleViewer, CustomerID: "customer-1"}
+	server := startFeed(t, broker, customer)
+
+	// Asking for another customer's orders does not widen the scope.
+	reader := connect(t, context.Background(), broker, server.URL+"?customer_id=customer-2", "")
+	broker.Publish(order("order-2", "customer-2"))
+	broker.Publish(order("order-1", "customer-1"))
+
+	events := readEvents(t, reader, 1)
+	if events[0].order.OrderUID != "order-1" {
+		t.Errorf("customer received %s, want only order-1", events[0].order.OrderUID)
+	}
+}
+
+func TestFeedReplaysFromLastEventID(t *testing.T) {
+	broker := NewBroker()
+	server := startFeed(t, broker, auth.Principal{Subject: "admin", Role: auth.RoleAdmin})
+
+	total := historySize + 100
+	for i := 0; i < total; i++ {
+		broker.Publish(order(fmt.Sprintf("order-%d", i), "customer-1"))
+	}
+	broker.mu.Lock()
+	if len(broker.history) != historySize {
+		t.Fatalf("history holds %d events, want %d", len(broker.history), historySize)
+	}
+	oldest := broker.history[0]
+	resumeFrom := broker.history[historySize-11]
+	broker.mu.Unlock()
+	if oldest.Order.OrderUID != "order-100" {
+		t.Fatalf("oldest event in the history is %s, want order-100", oldest.Order.OrderUID)
+	}
+
+	reader := connect(t, context.Background(), broker, server.URL, strconv.FormatUint(resumeFrom.ID, 10))
+	events := readEvents(t, reader, 10)
+	for i, event := range events {
+		if want := fmt.Sprintf("order-%d", total-10+i); event.order.OrderUID != want || event.id <= resumeFrom.ID {
+			t.Fatalf("replayed event %d = %d %s, want %s", i, event.id, event.order.OrderUID, want)
+		}
+	}
+
+	// Live events follow the replayed ones.
+	broker.Publish(order("order-live", "customer-1"))
+	if live := readEvents(t, reader, 1)[0]; live.order.OrderUID != "order-live" {
+		t.Errorf("live event = %s, want order-live", live.order.OrderUID)
+	}
+}
+
+func TestFeedRejectsInvalidLastEventID(t *testing.T) {
+	broker := NewBroker()
+	server := startFeed(t, broker, auth.Principal{Subject: "admin", Role: auth.RoleAdmin})
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Last-Event-ID", "not-a-number")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestFeedUnsubscribesOnDisconnect(t *testing.T) {
+	broker := NewBroker()
+	server := startFeed(t, broker, auth.Principal{Subject: "admin", Role: auth.RoleAdmin})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	connect(t, ctx, broker, server.URL, "")
+	cancel()
+
+	waitSubscribers(t, broker, 0)
+	// Publishing after the disconnect does not block or panic.
+	broker.Publish(order("order-1", "customer-1"))
+}
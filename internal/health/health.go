@@ -0,0 +1,172 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const checkTimeout = 2 * time.Second
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded"
+)
+
+// Check reports the state of a dependency and, when known, its version.
+type Check func(ctx context.Context) (version string, err error)
+
+type Component struct {
+	Status    string  `json:"status"`
+	Version   string  `json:"version,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+type Report struct {
+	Status     string               `json:"status"`
+	Version    string               `json:"version"`
+	GoVersion  string               `json:"go_version"`
+	Uptime     string               `json:"uptime"`
+	Components map[string]Component `json:"components"`
+}
+
+type named struct {
+	name     string
+	check    Check
+	optional bool
+}
+
+// Checker runs the registered dependency checks for the readiness and
+// status endpoints.
+type Checker struct {
+	mu      sync.Mutex
+	checks  []named
+	started time.Time
+}
+
+func NewChecker() *Checker {
+	return &Checker{started: time.Now()}
+}
+
+// Add registers a dependency the service cannot work without.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, named{name: name, check: check})
+}
+
+// AddOptional registers a dependency whose failure degrades the service
+// but keeps it ready.
+func (c *Checker) AddOptional(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, named{name: name, check: check, optional: true})
+}
+
+// Run executes all checks concurrently.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.Lock()
+	checks := append([]named(nil), c.checks...)
+	c.mu.Unlock()
+
+	report := Report{
+		Status:     StatusUp,
+		Version:    buildVersion(),
+		GoVersion:  runtime.Version(),
+		Uptime:     time.Since(c.started).Round(time.Second).String(),
+		Components: make(map[string]Component, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range checks {
+		wg.Add(1)
+		go func(nc named) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			version, err := nc.check(ctx)
+			component := Component{
+				Status:    StatusUp,
+				Version:   version,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				component.Status = StatusDown
+				component.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Components[nc.name] = component
+			switch {
+			case err == nil:
+			case !nc.optional:
+				report.Status = StatusDown
+			case report.Status == StatusUp:
+				report.Status = StatusDegraded
+			}
+			mu.Unlock()
+		}(nc)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// Liveness answers as long as the process serves HTTP.
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// Readiness returns 503 while any required dependency check fails.
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+	components := make(map[string]string, len(report.Components))
+	for name, component := range report.Components {
+		components[name] = component.Status
+	}
+	writeJSON(w, report.Status, map[string]interface{}{
+		"status":     report.Status,
+		"components": components,
+	})
+}
+
+// Status returns the detailed report with versions and errors.
+func (c *Checker) Status(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+	writeJSON(w, report.Status, report)
+}
+
+func writeJSON(w http.ResponseWriter, status string, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if status == StatusDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Error while creating health response", "error", err)
+	}
+}
+
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return info.Main.Version
+}
@@ -0,0 +1,193 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config holds the server limits. Zero values are replaced by defaults.
+type Config struct {
+	Addr              string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// MaxBodyBytes caps request bodies, see LimitBody.
+	MaxBodyBytes int64
+
+	// TLSCertFile and TLSKeyFile enable HTTPS with HTTP/2.
+	TLSCertFile string
+	TLSKeyFile  string
+	// RedirectAddr, with TLS enabled, starts a plain HTTP listener that
+	// redirects to HTTPS. Setting it without TLS is an error.
+	RedirectAddr string
+}
+
+var ErrRedirectWithoutTLS = errors.New("HTTP redirect address is set but TLS is not configured")
+
+// DefaultConfig keeps slow clients from holding connections forever.
+func DefaultConfig() Config {
+	return Config{
+		Addr:              ":3000",
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    64 << 10,
+		MaxBodyBytes:      1 << 20,
+	}
+}
+
+// ConfigFromEnv returns DefaultConfig with the overrides of HTTP_ADDR,
+// HTTP_READ_HEADER_TIMEOUT, HTTP_READ_TIMEOUT, HTTP_WRITE_TIMEOUT,
+// HTTP_IDLE_TIMEOUT, HTTP_MAX_BODY_BYTES, HTTP_TLS_CERT_FILE,
+// HTTP_TLS_KEY_FILE and HTTP_REDIRECT_ADDR. Invalid values are ignored.
+func ConfigFromEnv() Config {
+	config := DefaultConfig()
+	if addr := os.Getenv("HTTP_ADDR"); addr != "" {
+		config.Addr = addr
+	}
+	config.TLSCertFile = os.Getenv("HTTP_TLS_CERT_FILE")
+	config.TLSKeyFile = os.Getenv("HTTP_TLS_KEY_FILE")
+	config.RedirectAddr = os.Getenv("HTTP_REDIRECT_ADDR")
+	durations := map[string]*time.Duration{
+		"HTTP_READ_HEADER_TIMEOUT": &config.ReadHeaderTimeout,
+		"HTTP_READ_TIMEOUT":        &config.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":       &config.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":        &config.IdleTimeout,
+	}
+	for name, target := range durations {
+		if value := os.Getenv(name); value != "" {
+			if d, err := time.ParseDuration(value); err == nil && d > 0 {
+				*target = d
+			} else {
+				slog.Warn("Ignoring invalid duration", "variable", name, "value", value)
+			}
+		}
+	}
+	if value := os.Getenv("HTTP_MAX_BODY_BYTES"); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+			config.MaxBodyBytes = n
+		} else {
+			slog.Warn("Ignoring invalid size", "variable", "HTTP_MAX_BODY_BYTES", "value", value)
+		}
+	}
+	return config
+}
+
+// Server is the HTTP or HTTPS server of the service with the optional
+// redirect listener.
+type Server struct {
+	server   *http.Server
+	redirect *http.Server
+	certs    *CertReloader
+}
+
+// New creates the server with the timeouts of config and the body cap
+// applied to every request. With a certificate configured it serves HTTPS,
+// failing if the key pair cannot be loaded.
+func New(config Config, handler http.Handler) (*Server, error) {
+	tlsEnabled := config.TLSCertFile != "" || config.TLSKeyFile != ""
+	if config.RedirectAddr != "" && !tlsEnabled {
+		return nil, ErrRedirectWithoutTLS
+	}
+
+	s := &Server{server: &http.Server{
+		Addr:              config.Addr,
+		Handler:           LimitBody(config.MaxBodyBytes)(handler),
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}}
+
+	if !tlsEnabled {
+		return s, nil
+	}
+	certs, err := NewCertReloader(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	s.certs = certs
+	s.server.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	if config.RedirectAddr != "" {
+		s.redirect = &http.Server{
+			Addr:              config.RedirectAddr,
+			Handler:           redirectHandler(config.Addr),
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+			IdleTimeout:       config.IdleTimeout,
+		}
+	}
+	return s, nil
+}
+
+// ListenAndServe serves until Shutdown or a listener fails. The certificate
+// is watched for changes until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if s.certs == nil {
+		slog.Info("HTTP server started", "addr", s.server.Addr)
+		return ignoreClosed(s.server.ListenAndServe())
+	}
+
+	go s.certs.Watch(ctx)
+
+	errs := make(chan error, 2)
+	if s.redirect != nil {
+		go func() {
+			slog.Info("HTTP redirect server started", "addr", s.redirect.Addr)
+			errs <- ignoreClosed(s.redirect.ListenAndServe())
+		}()
+	}
+	go func() {
+		slog.Info("HTTPS server started", "addr", s.server.Addr)
+		errs <- ignoreClosed(s.server.ListenAndServeTLS("", ""))
+	}()
+	return <-errs
+}
+
+// Shutdown stops the listeners, waiting for in-flight requests until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.redirect != nil {
+		if err := s.redirect.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	return s.server.Shutdown(ctx)
+}
+
+func ignoreClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// LimitBody rejects bodies over max bytes. Requests declaring a larger
+// Content-Length get 413 at once, others fail when the handler reads past
+// the limit.
+func LimitBody(max int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > max {
+				http.Error(w, "request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
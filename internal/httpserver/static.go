@@ -0,0 +1,180 @@
+package httpserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minCompressSize skips compression where the gzip header outweighs the gain.
+	minCompressSize = 512
+
+	// Pages are revalidated on every load so a new release shows at once,
+	// other assets are reused for a while.
+	pageCacheControl  = "no-cache"
+	assetCacheControl = "public, max-age=3600"
+	devCacheControl   = "no-store"
+)
+
+// Static serves the files of a file system with their MIME type, an ETag
+// and gzip compression for clients that accept it. Embedded files never
+// change, so they are read and compressed once. In dev mode the files are
+// read on every request, which allows editing them without a rebuild.
+type Static struct {
+	fsys fs.FS
+	dev  bool
+
+	mu    sync.RWMutex
+	files map[string]*staticFile
+}
+
+type staticFile struct {
+	content     []byte
+	gzipped     []byte
+	contentType string
+	etag        string
+}
+
+func NewStatic(fsys fs.FS, dev bool) *Static {
+	return &Static{fsys: fsys, dev: dev, files: make(map[string]*staticFile)}
+}
+
+// ServeHTTP serves the file named by the request path, index.html for "/".
+func (static *Static) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	static.ServeFile(w, r, name)
+}
+
+func (static *Static) ServeFile(w http.ResponseWriter, r *http.Request, name string) {
+	file, err := static.open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		slog.Error("Error reading page", "path", name, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", file.contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	switch {
+	case static.dev:
+		header.Set("Cache-Control", devCacheControl)
+	case strings.HasPrefix(file.contentType, "text/html"):
+		header.Set("Cache-Control", pageCacheControl)
+	default:
+		header.Set("Cache-Control", assetCacheControl)
+	}
+
+	content, etag := file.content, file.etag
+	if file.gzipped != nil {
+		header.Add("Vary", "Accept-Encoding")
+		if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			// The encodings differ byte for byte, so they need distinct ETags.
+			content, etag = file.gzipped, strings.TrimSuffix(file.etag, `"`)+`-gzip"`
+			header.Set("Content-Encoding", "gzip")
+		}
+	}
+	header.Set("ETag", etag)
+	// ServeContent answers If-None-Match and HEAD requests.
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
+
+func (static *Static) open(name string) (*staticFile, error) {
+	if !static.dev {
+		static.mu.RLock()
+		file, ok := static.files[name]
+		static.mu.RUnlock()
+		if ok {
+			return file, nil
+		}
+	}
+
+	content, err := fs.ReadFile(static.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	file := newStaticFile(name, content)
+	if !static.dev {
+		static.mu.Lock()
+		static.files[name] = file
+		static.mu.Unlock()
+	}
+	return file, nil
+}
+
+func newStaticFile(name string, content []byte) *staticFile {
+	sum := sha256.Sum256(content)
+	file := &staticFile{
+		content:     content,
+		contentType: contentType(name),
+		etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+	}
+	if len(content) >= minCompressSize && compressible(file.contentType) {
+		var buf bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		gz.Write(content)
+		gz.Close()
+		if buf.Len() < len(content) {
+			file.gzipped = buf.Bytes()
+		}
+	}
+	return file
+}
+
+// contentType maps the extension to a MIME type. The platform tables differ,
+// so the types of the UI files are fixed here.
+func contentType(name string) string {
+	switch ext := path.Ext(name); ext {
+	case ".html":
+		return "text/html; charset=utf-8"
+	case ".css":
+		return "text/css; charset=utf-8"
+	case ".js":
+		return "text/javascript; charset=utf-8"
+	case ".json":
+		return "application/json"
+	case ".svg":
+		return "image/svg+xml"
+	default:
+		if t := mime.TypeByExtension(ext); t != "" {
+			return t
+		}
+		return "application/octet-stream"
+	}
+}
+
+func compressible(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.HasPrefix(contentType, "application/json") ||
+		strings.HasPrefix(contentType, "image/svg+xml")
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package httpserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var page = strings.Repeat("<p>order</p>\n", 100)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html": {Data: []byte(page)},
+		"small.css":  {Data: []byte("body{}")},
+	}
+}
+
+func serve(static *Static, path string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	static.ServeHTTP(w, r)
+	return w
+}
+
+func TestStaticGzip(t *testing.T) {
+	static := NewStatic(testFS(), false)
+
+	w := serve(static, "/", http.Header{"Accept-Encoding": {"gzip, deflate"}})
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("gzip request = %d, encoding %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding", w.Header().Get("Vary"))
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil || string(body) != page {
+		t.Fatalf("decompressed body differs from the page: %v", err)
+	}
+	gzipETag := w.Header().Get("ETag")
+
+	w = serve(static, "/", http.Header{"Accept-Encoding": {"gzip;q=0"}})
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != page {
+		t.Errorf("gzip;q=0 got encoding %q", w.Header().Get("Content-Encoding"))
+	}
+	if etag := w.Header().Get("ETag"); etag == gzipETag {
+		t.Errorf("plain and gzip responses share ETag %s", etag)
+	}
+
+	w = serve(static, "/small.css", http.Header{"Accept-Encoding": {"gzip"}})
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "body{}" {
+		t.Errorf("small file is compressed")
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/css; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestStaticETag(t *testing.T) {
+	static := NewStatic(testFS(), false)
+
+	w := serve(static, "/index.html", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first request = %d with ETag %q", w.Code, etag)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != pageCacheControl {
+		t.Errorf("Cache-Control = %q, want %q", cc, pageCacheControl)
+	}
+
+	w = serve(static, "/index.html", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("matching If-None-Match = %d with %d bytes, want 304 without a body", w.Code, w.Body.Len())
+	}
+
+	w = serve(static, "/index.html", http.Header{"If-None-Match": {`"other"`}})
+	if w.Code != http.StatusOK {
+		t.Errorf("other If-None-Match = %d, want 200", w.Code)
+	}
+
+	w = serve(static, "/missing.js", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing file = %d, want 404", w.Code)
+	}
+}
+
+func TestStaticDevModeRereads(t *testing.T) {
+	fsys := testFS()
+	static := NewStatic(fsys, true)
+
+	w := serve(static, "/small.css", nil)
+	if cc := w.Header().Get("Cache-Control"); cc != devCacheControl {
+		t.Errorf("Cache-Control = %q, want %q", cc, devCacheControl)
+	}
+	etag := w.Header().Get("ETag")
+
+	fsys["small.css"] = &fstest.MapFile{Data: []byte("body{color:red}")}
+	w = serve(static, "/small.css", nil)
+	if !bytes.Equal(w.Body.Bytes(), []byte("body{color:red}")) || w.Header().Get("ETag") == etag {
+		t.Errorf("dev mode served the old file %q", w.Body.String())
+	}
+}
@@ -0,0 +1,127 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often the certificate files are checked for changes.
+const certCheckInterval = 30 * time.Second
+
+// CertReloader serves the certificate from disk and reloads it when the
+// certificate or key file changes, so renewed certificates are picked up
+// without a restart.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+// NewCertReloader loads the key pair, failing if it is invalid.
+func NewCertReloader(certFile string, keyFile string) (*CertReloader, error) {
+	reloader := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (reloader *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+	return reloader.cert, nil
+}
+
+// Watch reloads the certificate after the files change until ctx is done.
+// A broken pair is logged and the previous certificate stays in use.
+func (reloader *CertReloader) Watch(ctx context.Context) {
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloader.check()
+		}
+	}
+}
+
+// check reloads the certificate if the files changed since the last load.
+func (reloader *CertReloader) check() {
+	modified, err := reloader.lastModified()
+	if err != nil {
+		slog.Warn("Unable to check TLS certificate", "error", err)
+		return
+	}
+	reloader.mu.RLock()
+	changed := modified.After(reloader.modified)
+	reloader.mu.RUnlock()
+	if !changed {
+		return
+	}
+	if err := reloader.reload(); err != nil {
+		slog.Error("Unable to reload TLS certificate, keeping the previous one", "error", err)
+		return
+	}
+	slog.Info("TLS certificate reloaded", "cert", reloader.certFile)
+}
+
+func (reloader *CertReloader) reload() error {
+	modified, err := reloader.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+	reloader.cert = &cert
+	reloader.modified = modified
+	return nil
+}
+
+// lastModified returns the later modification time of the two files.
+func (reloader *CertReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{reloader.certFile, reloader.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// redirectHandler sends clients to the same URL on the HTTPS listener.
+func redirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
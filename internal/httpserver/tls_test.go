@@ -0,0 +1,143 @@
+package httpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self-signed certificate for commonName and sets the
+// modification time of both files.
+func writeKeyPair(t *testing.T, certFile string, keyFile string, commonName string, modified time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), modified)
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), modified)
+}
+
+func writeFile(t *testing.T, path string, data []byte, modified time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedName(t *testing.T, reloader *CertReloader) string {
+	t.Helper()
+	cert, err := reloader.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Hour)
+	writeKeyPair(t, certFile, keyFile, "first", start)
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := servedName(t, reloader); name != "first" {
+		t.Fatalf("served %q, want first", name)
+	}
+
+	// Unchanged files are not reloaded even if their content differs.
+	writeKeyPair(t, certFile, keyFile, "same mtime", start)
+	reloader.check()
+	if name := servedName(t, reloader); name != "first" {
+		t.Fatalf("served %q without an mtime change, want first", name)
+	}
+
+	writeKeyPair(t, certFile, keyFile, "renewed", start.Add(time.Minute))
+	reloader.check()
+	if name := servedName(t, reloader); name != "renewed" {
+		t.Fatalf("served %q after renewal, want renewed", name)
+	}
+
+	// A broken pair keeps the previous certificate.
+	writeFile(t, keyFile, []byte("not a key"), start.Add(2*time.Minute))
+	reloader.check()
+	if name := servedName(t, reloader); name != "renewed" {
+		t.Fatalf("served %q after a broken renewal, want renewed", name)
+	}
+}
+
+func TestNewCertReloaderRejectsInvalidPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeKeyPair(t, certFile, keyFile, "first", time.Now())
+	writeFile(t, keyFile, []byte("not a key"), time.Now())
+
+	if _, err := NewCertReloader(certFile, keyFile); err == nil {
+		t.Error("invalid key pair is accepted")
+	}
+}
+
+func TestNewRejectsRedirectWithoutTLS(t *testing.T) {
+	config := DefaultConfig()
+	config.RedirectAddr = ":8080"
+	if _, err := New(config, http.NotFoundHandler()); !errors.Is(err, ErrRedirectWithoutTLS) {
+		t.Errorf("New = %v, want ErrRedirectWithoutTLS", err)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		httpsAddr string
+		host      string
+		want      string
+	}{
+		{":443", "example.com", "https://example.com/order/1?x=1"},
+		{":443", "example.com:80", "https://example.com/order/1?x=1"},
+		{":8443", "example.com:8080", "https://example.com:8443/order/1?x=1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/order/1?x=1", nil)
+		w := httptest.NewRecorder()
+		redirectHandler(tt.httpsAddr).ServeHTTP(w, r)
+		if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != tt.want {
+			t.Errorf("redirect of %s = %d %q, want %q", tt.host, w.Code, w.Header().Get("Location"), tt.want)
+		}
+	}
+}
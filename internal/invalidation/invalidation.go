@@ -0,0 +1,80 @@
+package invalidation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"test-task/internal/logger"
+)
+
+// Topic is consumed by every instance from the newest offset, so each
+// instance receives all events.
+const Topic = "order_cache_invalidation"
+
+// Event tells other instances that an order was changed. Version is the
+// order version after the change, zero when it is unknown.
+type Event struct {
+	OrderUID string `json:"order_uid"`
+	Version  int    `json:"version"`
+	Source   string `json:"source"`
+}
+
+// Transport delivers events to all instances, including the sender.
+type Transport interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Cache is the local cache kept consistent with the other instances.
+type Cache interface {
+	InvalidateOrder(ctx context.Context, order_uid string, version int)
+}
+
+type Service struct {
+	instanceID string
+	transport  Transport
+	cache      Cache
+}
+
+func NewService(instanceID string, transport Transport, cache Cache) *Service {
+	return &Service{instanceID: instanceID, transport: transport, cache: cache}
+}
+
+// InstanceID identifies this process among the replicas.
+func InstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return hostname + "-" + logger.NewRequestID()[:8]
+}
+
+// OrderChanged notifies the other instances after a local change.
+func (s *Service) OrderChanged(ctx context.Context, order_uid string, version int) {
+	event := Event{OrderUID: order_uid, Version: version, Source: s.instanceID}
+	if err := s.transport.Publish(ctx, event); err != nil {
+		logger.FromContext(ctx).Warn("Failed to publish cache invalidation",
+			"order_uid", order_uid, "error", err)
+	}
+}
+
+// Handle applies an event from another instance to the local cache.
+func (s *Service) Handle(ctx context.Context, event Event) {
+	if event.Source == s.instanceID {
+		return
+	}
+	logger.FromContext(ctx).Debug("Cache invalidation received",
+		"order_uid", event.OrderUID, "version", event.Version, "source", event.Source)
+	s.cache.InvalidateOrder(ctx, event.OrderUID, event.Version)
+}
+
+// HandleMessage decodes an event from a Kafka message payload.
+func (s *Service) HandleMessage(ctx context.Context, payload string) error {
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return fmt.Errorf("decode cache invalidation: %w", err)
+	}
+	s.Handle(ctx, event)
+	return nil
+}
@@ -0,0 +1,69 @@
+package invalidation
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// bus delivers every event to all services, the sender included, the way
+// the Kafka topic does.
+type bus struct {
+	services []*Service
+}
+
+func (b *bus) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	for _, s := range b.services {
+		if err := s.HandleMessage(ctx, string(payload)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type invalidation struct {
+	orderUID string
+	version  int
+}
+
+type recordingCache struct {
+	mu   sync.Mutex
+	seen []invalidation
+}
+
+func (cache *recordingCache) InvalidateOrder(ctx context.Context, order_uid string, version int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.seen = append(cache.seen, invalidation{orderUID: order_uid, version: version})
+}
+
+func TestOrderChangedFansOutToOtherInstances(t *testing.T) {
+	ctx := context.Background()
+	transport := &bus{}
+	cacheA, cacheB := &recordingCache{}, &recordingCache{}
+	a := NewService("a", transport, cacheA)
+	b := NewService("b", transport, cacheB)
+	transport.services = []*Service{a, b}
+
+	a.OrderChanged(ctx, "order-1", 2)
+	b.OrderChanged(ctx, "order-2", 5)
+
+	if len(cacheA.seen) != 1 || cacheA.seen[0] != (invalidation{"order-2", 5}) {
+		t.Errorf("instance a invalidated %v, want only order-2 version 5", cacheA.seen)
+	}
+	if len(cacheB.seen) != 1 || cacheB.seen[0] != (invalidation{"order-1", 2}) {
+		t.Errorf("instance b invalidated %v, want only order-1 version 2", cacheB.seen)
+	}
+}
+
+func TestHandleMessageRejectsInvalidPayload(t *testing.T) {
+	s := NewService("a", &bus{}, &recordingCache{})
+	if err := s.HandleMessage(context.Background(), "{"); err == nil {
+		t.Fatal("invalid payload is accepted")
+	}
+}
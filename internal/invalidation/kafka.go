@@ -0,0 +1,20 @@
+package invalidation
+
+import (
+	"context"
+
+	"test-task/internal/kafka"
+)
+
+// KafkaTransport publishes events to Topic through the service connection.
+type KafkaTransport struct {
+	Conn *kafka.Connection
+}
+
+func (t KafkaTransport) Publish(ctx context.Context, event Event) error {
+	producer := t.Conn.Producer()
+	if producer == nil {
+		return kafka.ErrNotConnected
+	}
+	return kafka.SendMessage(ctx, producer, event, Topic)
+}
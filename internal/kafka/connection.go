@@ -0,0 +1,184 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"test-task/internal/retry"
+
+	"github.com/IBM/sarama"
+)
+
+const healthInterval = 10 * time.Second
+
+var ErrNotConnected = errors.New("kafka is not connected")
+
+// ConnectFunc is called with the clients of a new connection. stopCh is
+// closed when the connection is lost or closed.
+type ConnectFunc func(producer sarama.SyncProducer, consumer sarama.Consumer, stopCh <-chan struct{})
+
+// Connection keeps the producer, consumer and metadata client of the
+// service. It connects in the background and reconnects after the broker
+// becomes unreachable, so the service can start and serve reads without Kafka.
+type Connection struct {
+	brokers  []string
+	backoff  retry.Backoff
+	security Security
+
+	mu        sync.RWMutex
+	producer  sarama.SyncProducer
+	consumer  sarama.Consumer
+	client    sarama.Client
+	stop      chan struct{}
+	onConnect []ConnectFunc
+}
+
+func NewConnection(brokers []string, backoff retry.Backoff, security Security) *Connection {
+	return &Connection{brokers: brokers, backoff: backoff, security: security}
+}
+
+// OnConnect registers a callback run after every successful (re)connect,
+// used to subscribe the service topics on the new consumer.
+func (c *Connection) OnConnect(fn ConnectFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onConnect = append(c.onConnect, fn)
+}
+
+func (c *Connection) Producer() sarama.SyncProducer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.producer
+}
+
+func (c *Connection) Consumer() sarama.Consumer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.consumer
+}
+
+// Clients returns the producer and consumer or ErrNotConnected.
+func (c *Connection) Clients() (sarama.SyncProducer, sarama.Consumer, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.producer == nil || c.consumer == nil {
+		return nil, nil, ErrNotConnected
+	}
+	return c.producer, c.consumer, nil
+}
+
+// Check refreshes the cluster metadata within the deadline of ctx.
+func (c *Connection) Check(ctx context.Context) (string, error) {
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+	if client == nil {
+		return "", ErrNotConnected
+	}
+	return CheckClient(ctx, client)
+}
+
+// Run connects with backoff and watches the broker until ctx is done.
+func (c *Connection) Run(ctx context.Context) {
+	for {
+		err := retry.Do(ctx, c.backoff, "kafka", func() error {
+			return c.connect()
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("Kafka connection attempts are exhausted", "error", err)
+			}
+			return
+		}
+		c.notify()
+
+		if !c.watch(ctx) {
+			return
+		}
+		slog.Warn("Kafka connection is lost, reconnecting")
+		c.disconnect()
+	}
+}
+
+func (c *Connection) connect() error {
+	client, err := ConnectClient(c.brokers, c.security)
+	if err != nil {
+		return err
+	}
+	producer, err := ConnectProducer(c.brokers, c.security)
+	if err != nil {
+		client.Close()
+		return err
+	}
+	consumer, err := ConnectConsumer(c.brokers, c.security)
+	if err != nil {
+		producer.Close()
+		client.Close()
+		return err
+	}
+
+	c.mu.Lock()
+	c.client, c.producer, c.consumer = client, producer, consumer
+	c.stop = make(chan struct{})
+	c.mu.Unlock()
+
+	slog.Info("Connected to Kafka", "brokers", c.brokers)
+	return nil
+}
+
+func (c *Connection) notify() {
+	c.mu.RLock()
+	producer, consumer, stop := c.producer, c.consumer, c.stop
+	callbacks := append([]ConnectFunc(nil), c.onConnect...)
+	c.mu.RUnlock()
+
+	for _, fn := range callbacks {
+		fn(producer, consumer, stop)
+	}
+}
+
+// watch returns false when ctx is done and true when the broker is lost.
+func (c *Connection) watch(ctx context.Context) bool {
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			if _, err := c.Check(ctx); err != nil {
+				slog.Warn("Kafka health check failed", "error", err)
+				return true
+			}
+		}
+	}
+}
+
+func (c *Connection) disconnect() {
+	c.mu.Lock()
+	producer, consumer, client, stop := c.producer, c.consumer, c.client, c.stop
+	c.producer, c.consumer, c.client, c.stop = nil, nil, nil, nil
+	c.mu.Unlock()
+
+	// Stop the service loops of the old connection before closing it.
+	if stop != nil {
+		close(stop)
+	}
+	if consumer != nil {
+		consumer.Close()
+	}
+	if producer != nil {
+		producer.Close()
+	}
+	if client != nil {
+		client.Close()
+	}
+}
+
+func (c *Connection) Close() {
+	c.disconnect()
+}
@@ -3,21 +3,41 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"time"
 
+	"test-task/internal/auth"
+	"test-task/internal/logger"
+	"test-task/internal/metrics"
+	"test-task/internal/retry"
+	"test-task/internal/tracing"
+
 	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func ConnectConsumer(brokers []string) (sarama.Consumer, error) {
+// ActorHeader names the caller on whose behalf a message is sent. Anyone who
+// can write to a topic can set it, it is recorded for the audit trail but
+// grants nothing.
+const ActorHeader = "X-Actor"
+
+func ConnectConsumer(brokers []string, security Security) (sarama.Consumer, error) {
 	config := sarama.NewConfig()
 	config.Consumer.Return.Errors = true
+	if err := security.apply(config); err != nil {
+		return nil, err
+	}
 
 	return sarama.NewConsumer(brokers, config)
 }
 
-func ConnectProducer(brokers []string) (sarama.SyncProducer, error) {
+func ConnectProducer(brokers []string, security Security) (sarama.SyncProducer, error) {
 	config := sarama.NewConfig()
+	if err := security.apply(config); err != nil {
+		return nil, err
+	}
 
 	config.Net.ReadTimeout = 10 * time.Second
 	config.Net.WriteTimeout = 10 * time.Second
@@ -32,15 +52,65 @@ func ConnectProducer(brokers []string) (sarama.SyncProducer, error) {
 	return sarama.NewSyncProducer(brokers, config)
 }
 
+// ConnectClient creates a client used to inspect cluster metadata.
+func ConnectClient(brokers []string, security Security) (sarama.Client, error) {
+	config := sarama.NewConfig()
+	config.Metadata.Full = false
+	config.Net.DialTimeout = 5 * time.Second
+	if err := security.apply(config); err != nil {
+		return nil, retry.Permanent(err)
+	}
+
+	client, err := sarama.NewClient(brokers, config)
+	var configErr sarama.ConfigurationError
+	if errors.As(err, &configErr) || errors.Is(err, sarama.ErrSASLAuthenticationFailed) {
+		return nil, retry.Permanent(err)
+	}
+	return client, err
+}
+
+// CheckClient refreshes cluster metadata. sarama cannot cancel the refresh,
+// so it runs in the background and is abandoned when ctx is done; the client
+// network timeouts bound how long it lingers. No version is reported, the
+// configured protocol version says nothing about the brokers.
+func CheckClient(ctx context.Context, client sarama.Client) (string, error) {
+	done := make(chan error, 1)
+	go func() {
+		if err := client.RefreshMetadata(); err != nil {
+			done <- err
+			return
+		}
+		if len(client.Brokers()) == 0 {
+			done <- errors.New("no brokers available")
+			return
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		return "", err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
 // DoRequest sends a message and waits for a response from Kafka
 func DoRequest[T any](
+	ctx context.Context,
 	producer sarama.SyncProducer,
 	consumer sarama.Consumer,
 	payload T,
 	topicReq string,
 	topicResp string) string {
+	ctx, span := tracing.Tracer().Start(ctx, topicReq+" request",
+		trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	log := logger.FromContext(ctx)
+
 	// Send message
-	if err := SendMessage(producer, payload, topicReq); err != nil {
+	if err := SendMessage(ctx, producer, payload, topicReq); err != nil {
 		return "Failed to send message: " + err.Error()
 	}
 
@@ -55,7 +125,7 @@ func DoRequest[T any](
 	defer partitionConsumer.Close()
 
 	// Context with timeout instead of time.After
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	var response string
@@ -65,11 +135,12 @@ func DoRequest[T any](
 			response = "Error while receiving message: " + errMsg.Err.Error()
 		}
 	case msg := <-partitionConsumer.Messages():
-		log.Printf("Topic=%s | Message=%s\n", msg.Topic, string(msg.Value))
+		metrics.KafkaConsumed(msg.Topic)
+		log.Debug("Response received", "topic", msg.Topic, "value", string(msg.Value))
 		response = string(msg.Value)
 	case <-ctx.Done():
 		response = "Response timeout expired"
-		log.Println(response)
+		log.Warn(response, "topic", topicResp)
 	}
 
 	return response
@@ -80,58 +151,89 @@ func DoServiceRequest(
 	producer sarama.SyncProducer,
 	c sarama.Consumer,
 	stopCh <-chan struct{},
-	operation func(string) (interface{}, error),
+	operation func(context.Context, string) (interface{}, error),
 	topicReq string,
 	topicResp string,
 ) {
-	consumer, err := c.ConsumePartition(topicReq, 0, sarama.OffsetNewest)
+	Subscribe(c, stopCh, topicReq, func(ctx context.Context, payload string) error {
+		result, err := operation(ctx, payload)
+		if err != nil {
+			result = err.Error()
+		}
+
+		if sendErr := SendMessage(ctx, producer, result, topicResp); sendErr != nil {
+			logger.FromContext(ctx).Error("Failed to send response", "topic", topicResp, "error", sendErr)
+		}
+		return err
+	})
+}
+
+// Subscribe consumes new messages of a topic and passes them to handle until
+// stopCh is closed. Each message is handled with the request id and trace
+// context restored from its headers.
+func Subscribe(
+	c sarama.Consumer,
+	stopCh <-chan struct{},
+	topic string,
+	handle func(context.Context, string) error,
+) {
+	log := slog.Default().With("topic", topic)
+
+	consumer, err := c.ConsumePartition(topic, 0, sarama.OffsetNewest)
 	if err != nil {
-		log.Printf("Failed to subscribe to topic %s: %v", topicReq, err)
+		log.Error("Failed to subscribe to topic", "error", err)
 		return
 	}
 
 	go func() {
-		defer log.Printf("Stopped listening on topic %s", topicReq)
+		defer log.Info("Stopped listening on topic")
+		defer consumer.Close()
 
 		for {
 			select {
+			case <-stopCh:
+				return
 			case errMsg, ok := <-consumer.Errors():
 				if !ok {
-					log.Printf("Error channel closed for topic %s", topicReq)
+					log.Warn("Error channel closed")
 					return
 				}
 				if errMsg != nil {
-					log.Printf("Consumer error: %v", errMsg.Err)
+					log.Error("Consumer error", "error", errMsg.Err)
 				}
 
 			case msg, ok := <-consumer.Messages():
 				if !ok {
-					log.Printf("Message channel closed for topic %s", topicReq)
+					log.Warn("Message channel closed")
 					return
 				}
 				if msg == nil {
 					continue
 				}
+				metrics.KafkaConsumed(msg.Topic)
+				metrics.KafkaLag(msg.Topic, msg.Partition, consumer.HighWaterMarkOffset()-msg.Offset-1)
 
-				payload := string(msg.Value)
-				log.Printf("Incoming message: Topic=%s | Value=%s", msg.Topic, payload)
+				ctx, span := tracing.Tracer().Start(
+					tracing.ExtractKafka(contextFromMessage(msg), msg),
+					msg.Topic+" process",
+					trace.WithSpanKind(trace.SpanKindConsumer))
+				msgLog := logger.FromContext(ctx).With("topic", msg.Topic)
+				msgLog.Debug("Incoming message", "offset", msg.Offset)
 
-				result, err := operation(payload)
-				if err != nil {
-					log.Printf("Operation failed: %v", err)
-					result = err.Error()
-				}
-
-				if sendErr := SendMessage(producer, result, topicResp); sendErr != nil {
-					log.Printf(" Failed to send response: %v", sendErr)
+				if err := handle(ctx, string(msg.Value)); err != nil {
+					msgLog.Error("Operation failed", "error", err)
+					metrics.KafkaFailed(msg.Topic, metrics.StageProcess)
+					span.SetStatus(codes.Error, err.Error())
 				}
+				span.End()
 			}
 		}
 	}()
 }
 
-// SendMessage serializes and sends a message to Kafka
-func SendMessage[T any](producer sarama.SyncProducer, payload T, topic string) error {
+// SendMessage serializes and sends a message to Kafka. The request id and
+// the actor of ctx are passed in the message headers.
+func SendMessage[T any](ctx context.Context, producer sarama.SyncProducer, payload T, topic string) error {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return err
@@ -141,12 +243,54 @@ func SendMessage[T any](producer sarama.SyncProducer, payload T, topic string) e
 		Topic: topic,
 		Value: sarama.StringEncoder(data),
 	}
+	ctx, span := tracing.Tracer().Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+
+	tracing.InjectKafka(ctx, msg)
+	if requestID := logger.RequestID(ctx); requestID != "" {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{
+			Key:   []byte(logger.RequestIDHeader),
+			Value: []byte(requestID),
+		})
+	}
+	if actor, ok := auth.Actor(ctx); ok {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{
+			Key:   []byte(ActorHeader),
+			Value: []byte(actor),
+		})
+	}
 
 	partition, offset, err := producer.SendMessage(msg)
 	if err != nil {
+		metrics.KafkaFailed(topic, metrics.StageProduce)
+		span.SetStatus(codes.Error, err.Error())
 		return err
 	}
+	metrics.KafkaProduced(topic)
 
-	log.Printf("Message sent: Topic=%s | Partition=%d | Offset=%d", topic, partition, offset)
+	logger.FromContext(ctx).Debug("Message sent", "topic", topic, "partition", partition, "offset", offset)
 	return nil
 }
+
+// contextFromMessage restores the request id from the message headers,
+// generating a new one for messages sent without it, and the actor.
+func contextFromMessage(msg *sarama.ConsumerMessage) context.Context {
+	ctx := context.Background()
+	requestID := ""
+	for _, header := range msg.Headers {
+		if header == nil {
+			continue
+		}
+		switch string(header.Key) {
+		case logger.RequestIDHeader:
+			requestID = string(header.Value)
+		case ActorHeader:
+			ctx = auth.WithActor(ctx, string(header.Value))
+		}
+	}
+	if requestID == "" {
+		requestID = logger.NewRequestID()
+	}
+	return logger.WithRequestID(ctx, requestID)
+}
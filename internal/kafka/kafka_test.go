@@ -0,0 +1,35 @@
+package kafka
+
+import (
+	"testing"
+
+	"test-task/internal/auth"
+	"test-task/internal/logger"
+
+	"github.com/IBM/sarama"
+)
+
+func TestContextFromMessage(t *testing.T) {
+	msg := &sarama.ConsumerMessage{Headers: []*sarama.RecordHeader{
+		{Key: []byte(logger.RequestIDHeader), Value: []byte("request-1")},
+		{Key: []byte(ActorHeader), Value: []byte("key:abcd")},
+	}}
+	ctx := contextFromMessage(msg)
+	if id := logger.RequestID(ctx); id != "request-1" {
+		t.Errorf("request id = %q, want request-1", id)
+	}
+	if actor, _ := auth.Actor(ctx); actor != "key:abcd" {
+		t.Errorf("actor = %q, want key:abcd", actor)
+	}
+	if _, ok := auth.FromContext(ctx); ok {
+		t.Error("the actor header is turned into a principal")
+	}
+
+	ctx = contextFromMessage(&sarama.ConsumerMessage{})
+	if logger.RequestID(ctx) == "" {
+		t.Error("no request id is generated")
+	}
+	if actor, ok := auth.Actor(ctx); ok {
+		t.Errorf("message without the header has actor %q", actor)
+	}
+}
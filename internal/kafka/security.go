@@ -0,0 +1,210 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/xdg-go/scram"
+)
+
+// defaultClientID is sent to the brokers when no client ID is configured.
+const defaultClientID = "orders-service"
+
+var ErrUnknownMechanism = errors.New("unknown SASL mechanism")
+
+// Security configures the identity and transport security shared by every
+// Kafka client of the service.
+type Security struct {
+	ClientID string
+	TLS      TLS
+	SASL     SASL
+}
+
+// TLS enables encrypted connections. CertFile and KeyFile set a client
+// certificate; without CAFile the system roots verify the brokers.
+type TLS struct {
+	Enabled            bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// SASL authenticates with one of PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512.
+// An empty Mechanism disables SASL.
+type SASL struct {
+	Mechanism string
+	Username  string
+	Password  string
+}
+
+// SecurityFromEnv reads KAFKA_CLIENT_ID, KAFKA_TLS_ENABLED, KAFKA_TLS_CA_FILE,
+// KAFKA_TLS_CERT_FILE, KAFKA_TLS_KEY_FILE, KAFKA_TLS_SERVER_NAME,
+// KAFKA_TLS_INSECURE_SKIP_VERIFY, KAFKA_SASL_MECHANISM, KAFKA_SASL_USERNAME
+// and KAFKA_SASL_PASSWORD. Setting any TLS option enables TLS, so a partial
+// TLS config never silently connects in plaintext; disabling TLS together
+// with other TLS options is rejected.
+func SecurityFromEnv() (Security, error) {
+	security := Security{
+		ClientID: os.Getenv("KAFKA_CLIENT_ID"),
+		TLS: TLS{
+			CAFile:     os.Getenv("KAFKA_TLS_CA_FILE"),
+			CertFile:   os.Getenv("KAFKA_TLS_CERT_FILE"),
+			KeyFile:    os.Getenv("KAFKA_TLS_KEY_FILE"),
+			ServerName: os.Getenv("KAFKA_TLS_SERVER_NAME"),
+		},
+		SASL: SASL{
+			Mechanism: strings.ToUpper(os.Getenv("KAFKA_SASL_MECHANISM")),
+			Username:  os.Getenv("KAFKA_SASL_USERNAME"),
+			Password:  os.Getenv("KAFKA_SASL_PASSWORD"),
+		},
+	}
+
+	var err error
+	if value := os.Getenv("KAFKA_TLS_INSECURE_SKIP_VERIFY"); value != "" {
+		if security.TLS.InsecureSkipVerify, err = strconv.ParseBool(value); err != nil {
+			return Security{}, fmt.Errorf("KAFKA_TLS_INSECURE_SKIP_VERIFY: %w", err)
+		}
+	}
+	if value := os.Getenv("KAFKA_TLS_ENABLED"); value != "" {
+		if security.TLS.Enabled, err = strconv.ParseBool(value); err != nil {
+			return Security{}, fmt.Errorf("KAFKA_TLS_ENABLED: %w", err)
+		}
+		if !security.TLS.Enabled && security.TLS.configured() {
+			return Security{}, errors.New("KAFKA_TLS_ENABLED is false but other KAFKA_TLS_ options are set")
+		}
+	}
+	if security.TLS.configured() {
+		security.TLS.Enabled = true
+	}
+
+	// Check the files and the mechanism at startup rather than on connect.
+	if _, err := security.TLS.config(); err != nil {
+		return Security{}, err
+	}
+	if err := security.SASL.validate(); err != nil {
+		return Security{}, err
+	}
+	return security, nil
+}
+
+// apply sets the client ID, TLS and SASL options on a client config.
+func (security Security) apply(config *sarama.Config) error {
+	config.ClientID = security.ClientID
+	if config.ClientID == "" {
+		config.ClientID = defaultClientID
+	}
+
+	tlsConfig, err := security.TLS.config()
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+
+	if security.SASL.Mechanism == "" {
+		return nil
+	}
+	if err := security.SASL.validate(); err != nil {
+		return err
+	}
+	config.Net.SASL.Enable = true
+	config.Net.SASL.Handshake = true
+	config.Net.SASL.User = security.SASL.Username
+	config.Net.SASL.Password = security.SASL.Password
+	config.Net.SASL.Mechanism = sarama.SASLMechanism(security.SASL.Mechanism)
+	switch security.SASL.Mechanism {
+	case sarama.SASLTypeSCRAMSHA256:
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hash: scram.SHA256}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hash: scram.SHA512}
+		}
+	}
+	return nil
+}
+
+// configured reports whether any option besides Enabled is set.
+func (t TLS) configured() bool {
+	return t.CAFile != "" || t.CertFile != "" || t.KeyFile != "" || t.ServerName != "" || t.InsecureSkipVerify
+}
+
+// config returns nil when TLS is disabled and no TLS option is set.
+func (t TLS) config() (*tls.Config, error) {
+	if !t.Enabled && !t.configured() {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read Kafka CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("read Kafka CA: no certificates in %s", t.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load Kafka client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func (s SASL) validate() error {
+	switch s.Mechanism {
+	case "":
+		return nil
+	case sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512:
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownMechanism, s.Mechanism)
+	}
+	if s.Username == "" {
+		return fmt.Errorf("SASL %s requires a username", s.Mechanism)
+	}
+	return nil
+}
+
+// scramClient adapts xdg-go/scram to sarama.SCRAMClient.
+type scramClient struct {
+	hash         scram.HashGeneratorFcn
+	conversation *scram.ClientConversation
+}
+
+func (c *scramClient) Begin(username, password, authzID string) error {
+	client, err := c.hash.NewClient(username, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
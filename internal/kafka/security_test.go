@@ -0,0 +1,46 @@
+package kafka
+
+import "testing"
+
+func TestSecurityFromEnvTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		enabled bool
+		wantErr bool
+	}{
+		{name: "no options", env: nil},
+		{name: "explicitly enabled", env: map[string]string{"KAFKA_TLS_ENABLED": "true"}, enabled: true},
+		{name: "server name only", env: map[string]string{"KAFKA_TLS_SERVER_NAME": "kafka.internal"}, enabled: true},
+		{name: "skip verify only", env: map[string]string{"KAFKA_TLS_INSECURE_SKIP_VERIFY": "true"}, enabled: true},
+		{name: "key without certificate", env: map[string]string{"KAFKA_TLS_KEY_FILE": "client.key"}, wantErr: true},
+		{name: "disabled with options", env: map[string]string{
+			"KAFKA_TLS_ENABLED": "false", "KAFKA_TLS_SERVER_NAME": "kafka.internal",
+		}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{
+				"KAFKA_TLS_ENABLED", "KAFKA_TLS_CA_FILE", "KAFKA_TLS_CERT_FILE", "KAFKA_TLS_KEY_FILE",
+				"KAFKA_TLS_SERVER_NAME", "KAFKA_TLS_INSECURE_SKIP_VERIFY", "KAFKA_SASL_MECHANISM",
+			} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			security, err := SecurityFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("partial TLS config is accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if security.TLS.Enabled != tt.enabled {
+				t.Fatalf("TLS enabled = %v, want %v", security.TLS.Enabled, tt.enabled)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package logger
+
+import (
+	"net/http"
+)
+
+// RequestIDMiddleware takes the request id from the X-Request-ID header or
+// generates a new one, stores it in the request context and echoes it back.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := WithRequestID(r.Context(), requestID)
+		FromContext(ctx).Debug("HTTP request", "method", r.Method, "path", r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ctxKey struct{}
+
+// RequestIDHeader carries the request id in HTTP requests and Kafka messages.
+const RequestIDHeader = "X-Request-ID"
+
+// Setup installs the default slog logger. Level is one of debug, info, warn,
+// error; format "json" switches from text to JSON output.
+func Setup(level string, format string) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// SetupFromEnv configures logging from LOG_LEVEL and LOG_FORMAT.
+func SetupFromEnv() {
+	Setup(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(ctxKey{}).(string)
+	return requestID
+}
+
+// FromContext returns the default logger annotated with the request id and
+// trace id of ctx.
+func FromContext(ctx context.Context) *slog.Logger {
+	log := slog.Default()
+	if requestID := RequestID(ctx); requestID != "" {
+		log = log.With("request_id", requestID)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		log = log.With("trace_id", spanContext.TraceID().String())
+	}
+	return log
+}
+
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
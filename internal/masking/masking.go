@@ -0,0 +1,183 @@
+package masking
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"test-task/internal/auth"
+	models "test-task/internal/models"
+)
+
+var (
+	ErrUnknownField = errors.New("unknown masked field")
+	ErrUnknownMask  = errors.New("unknown mask")
+)
+
+// Mask hides part of a value.
+type Mask func(value string) string
+
+// Masks are the styles a rule can refer to by name.
+var Masks = map[string]Mask{
+	"phone": Phone,
+	"email": Email,
+	"name":  Name,
+	"tail":  Tail,
+	"full":  Full,
+}
+
+// fields maps the JSON path of every maskable field to its value.
+var fields = map[string]func(order *models.Order) *string{
+	"delivery.name":       func(order *models.Order) *string { return &order.Delivery.Name },
+	"delivery.phone":      func(order *models.Order) *string { return &order.Delivery.Phone },
+	"delivery.email":      func(order *models.Order) *string { return &order.Delivery.Email },
+	"delivery.address":    func(order *models.Order) *string { return &order.Delivery.Address },
+	"delivery.zip":        func(order *models.Order) *string { return &order.Delivery.Zip },
+	"delivery.city":       func(order *models.Order) *string { return &order.Delivery.City },
+	"delivery.region":     func(order *models.Order) *string { return &order.Delivery.Region },
+	"payment.transaction": func(order *models.Order) *string { return &order.Payment.Transaction },
+	"payment.request_id":  func(order *models.Order) *string { return &order.Payment.RequestID },
+	"customer_id":         func(order *models.Order) *string { return &order.CustomerID },
+}
+
+// Rule shows a field in clear to callers with at least Role and masks it
+// for everyone else.
+type Rule struct {
+	Role     auth.Role
+	MaskName string
+	Mask     Mask
+}
+
+// Policy holds the rules by field path. The HTTP handlers apply it, Kafka
+// replies go to trusted services unmasked.
+type Policy struct {
+	rules map[string]Rule
+}
+
+// DefaultPolicy shows personal data only to admins and payment references
+// to operators.
+func DefaultPolicy() *Policy {
+	return &Policy{rules: map[string]Rule{
+		"delivery.name":       {Role: auth.RoleAdmin, MaskName: "name", Mask: Name},
+		"delivery.phone":      {Role: auth.RoleAdmin, MaskName: "phone", Mask: Phone},
+		"delivery.email":      {Role: auth.RoleAdmin, MaskName: "email", Mask: Email},
+		"delivery.address":    {Role: auth.RoleAdmin, MaskName: "full", Mask: Full},
+		"delivery.zip":        {Role: auth.RoleAdmin, MaskName: "full", Mask: Full},
+		"payment.transaction": {Role: auth.RoleOperator, MaskName: "tail", Mask: Tail},
+		"payment.request_id":  {Role: auth.RoleOperator, MaskName: "tail", Mask: Tail},
+	}}
+}
+
+// Set adds or replaces the rule of a field.
+func (policy *Policy) Set(field string, role auth.Role, maskName string) error {
+	if _, ok := fields[field]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownField, field)
+	}
+	mask, ok := Masks[maskName]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownMask, maskName)
+	}
+	policy.rules[field] = Rule{Role: role, MaskName: maskName, Mask: mask}
+	return nil
+}
+
+// Parse applies comma separated "field=role[:mask]" overrides on top of the
+// policy. Without a mask the current one of the field is kept, "full" for a
+// field that had no rule.
+func (policy *Policy) Parse(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		field, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("masking rule %q: expected field=role[:mask]", item)
+		}
+		field = strings.TrimSpace(field)
+		roleName, maskName, _ := strings.Cut(value, ":")
+		role, err := auth.ParseRole(roleName)
+		if err != nil {
+			return fmt.Errorf("masking rule %q: %w", item, err)
+		}
+		if maskName == "" {
+			maskName = "full"
+			if rule, ok := policy.rules[field]; ok {
+				maskName = rule.MaskName
+			}
+		}
+		if err := policy.Set(field, role, strings.TrimSpace(maskName)); err != nil {
+			return fmt.Errorf("masking rule %q: %w", item, err)
+		}
+	}
+	return nil
+}
+
+// PolicyFromEnv returns the default policy with the overrides of MASKING_RULES.
+func PolicyFromEnv() (*Policy, error) {
+	policy := DefaultPolicy()
+	if err := policy.Parse(os.Getenv("MASKING_RULES")); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Apply returns a copy of the order with the fields the principal may not
+// see masked. Customers always see their own orders in clear.
+func (policy *Policy) Apply(order models.Order, principal auth.Principal) models.Order {
+	if principal.CustomerID != "" && principal.CustomerID == order.CustomerID {
+		return order
+	}
+	for field, rule := range policy.rules {
+		if principal.Role >= rule.Role {
+			continue
+		}
+		value := fields[field](&order)
+		if *value != "" {
+			*value = rule.Mask(*value)
+		}
+	}
+	return order
+}
+
+// Phone keeps the first two and last four characters: +7***1234.
+func Phone(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 6 {
+		return Full(value)
+	}
+	return string(runes[:2]) + "***" + string(runes[len(runes)-4:])
+}
+
+// Email keeps the first character of the local part and the domain.
+func Email(value string) string {
+	local, domain, ok := strings.Cut(value, "@")
+	if !ok || local == "" {
+		return Full(value)
+	}
+	return string([]rune(local)[:1]) + "***@" + domain
+}
+
+// Name keeps the first letter of every word.
+func Name(value string) string {
+	words := strings.Fields(value)
+	for i, word := range words {
+		words[i] = string([]rune(word)[:1]) + "***"
+	}
+	return strings.Join(words, " ")
+}
+
+// Tail keeps the last four characters.
+func Tail(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 4 {
+		return Full(value)
+	}
+	return "***" + string(runes[len(runes)-4:])
+}
+
+// Full hides the whole value.
+func Full(string) string {
+	return "***"
+}
@@ -0,0 +1,187 @@
+package masking
+
+import (
+	"errors"
+	"testing"
+
+	"test-task/internal/auth"
+	models "test-task/internal/models"
+)
+
+func testOrder() models.Order {
+	return models.Order{
+		CustomerID: "customer-1",
+		Delivery: models.Delivery{
+			Name:    "Ivan Petrov",
+			Phone:   "+79001234567",
+			Email:   "ivan@example.com",
+			Address: "Lenina 1",
+			Zip:     "123456",
+			City:    "Moscow",
+		},
+		Payment: models.Payment{
+			Transaction: "b563feb7b2b84b6test",
+			RequestID:   "",
+		},
+	}
+}
+
+func TestMasks(t *testing.T) {
+	tests := []struct {
+		mask  Mask
+		value string
+		want  string
+	}{
+		{Phone, "+79001234567", "+7***4567"},
+		{Phone, "123456", "***"},
+		{Email, "ivan@example.com", "i***@example.com"},
+		{Email, "@example.com", "***"},
+		{Email, "no-at-sign", "***"},
+		{Name, "Ivan Petrov", "I*** P***"},
+		{Name, "Иван", "И***"},
+		{Tail, "b563feb7b2b84b6test", "***test"},
+		{Tail, "test", "***"},
+		{Full, "anything", "***"},
+	}
+	for _, tt := range tests {
+		if got := tt.mask(tt.value); got != tt.want {
+			t.Errorf("mask(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultPolicyApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		want      func(order *models.Order)
+	}{
+		{
+			name:      "viewer",
+			principal: auth.Principal{Subject: "viewer", Role: auth.RoleViewer},
+			want: func(order *models.Order) {
+				order.Delivery.Name = "I*** P***"
+				order.Delivery.Phone = "+7***4567"
+				order.Delivery.Email = "i***@example.com"
+				order.Delivery.Address = "***"
+				order.Delivery.Zip = "***"
+				order.Payment.Transaction = "***test"
+			},
+		},
+		{
+			name:      "operator sees payment references",
+			principal: auth.Principal{Subject: "operator", Role: auth.RoleOperator},
+			want: func(order *models.Order) {
+				order.Delivery.Name = "I*** P***"
+				order.Delivery.Phone = "+7***4567"
+				order.Delivery.Email = "i***@example.com"
+				order.Delivery.Address = "***"
+				order.Delivery.Zip = "***"
+			},
+		},
+		{
+			name:      "admin sees everything",
+			principal: auth.Principal{Subject: "admin", Role: auth.RoleAdmin},
+			want:      func(order *models.Order) {},
+		},
+		{
+			name:      "customer sees own order",
+			principal: auth.Principal{Subject: "customer", Role: auth.RoleViewer, CustomerID: "customer-1"},
+			want:      func(order *models.Order) {},
+		},
+		{
+			name:      "customer of another order",
+			principal: auth.Principal{Subject: "customer", Role: auth.RoleViewer, CustomerID: "customer-2"},
+			want: func(order *models.Order) {
+				order.Delivery.Name = "I*** P***"
+				order.Delivery.Phone = "+7***4567"
+				order.Delivery.Email = "i***@example.com"
+				order.Delivery.Address = "***"
+				order.Delivery.Zip = "***"
+				order.Payment.Transaction = "***test"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := testOrder()
+			tt.want(&want)
+			got := DefaultPolicy().Apply(testOrder(), tt.principal)
+			if got.Delivery != want.Delivery || got.Payment != want.Payment || got.CustomerID != want.CustomerID {
+				t.Errorf("Apply = %+v %+v, want %+v %+v", got.Delivery, got.Payment, want.Delivery, want.Payment)
+			}
+		})
+	}
+}
+
+func TestApplyKeepsOriginal(t *testing.T) {
+	order := testOrder()
+	DefaultPolicy().Apply(order, auth.Principal{Role: auth.RoleViewer})
+	if order.Delivery.Phone != "+79001234567" {
+		t.Errorf("Apply changed the original order: %q", order.Delivery.Phone)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		field   string
+		want    Rule
+		wantErr error
+		invalid bool
+	}{
+		{name: "empty", spec: "", field: "delivery.phone",
+			want: Rule{Role: auth.RoleAdmin, MaskName: "phone"}},
+		{name: "role keeps the mask", spec: "delivery.phone=operator", field: "delivery.phone",
+			want: Rule{Role: auth.RoleOperator, MaskName: "phone"}},
+		{name: "role and mask", spec: " delivery.city = viewer:tail , ", field: "delivery.city",
+			want: Rule{Role: auth.RoleViewer, MaskName: "tail"}},
+		{name: "new field defaults to full", spec: "customer_id=operator", field: "customer_id",
+			want: Rule{Role: auth.RoleOperator, MaskName: "full"}},
+		{name: "unknown field", spec: "delivery.country=admin", wantErr: ErrUnknownField},
+		{name: "unknown mask", spec: "delivery.phone=admin:hash", wantErr: ErrUnknownMask},
+		{name: "unknown role", spec: "delivery.phone=root", wantErr: auth.ErrUnknownRole},
+		{name: "missing role", spec: "delivery.phone", invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := DefaultPolicy()
+			err := policy.Parse(tt.spec)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Parse(%q) = %v, want %v", tt.spec, err, tt.wantErr)
+				}
+				return
+			case tt.invalid:
+				if err == nil {
+					t.Fatalf("Parse(%q) accepted a malformed rule", tt.spec)
+				}
+				return
+			case err != nil:
+				t.Fatalf("Parse(%q) = %v", tt.spec, err)
+			}
+			rule := policy.rules[tt.field]
+			if rule.Role != tt.want.Role || rule.MaskName != tt.want.MaskName || rule.Mask == nil {
+				t.Errorf("rule of %s = %v:%s, want %v:%s", tt.field, rule.Role, rule.MaskName, tt.want.Role, tt.want.MaskName)
+			}
+		})
+	}
+}
+
+func TestPolicyFromEnv(t *testing.T) {
+	t.Setenv("MASKING_RULES", "delivery.email=viewer:full")
+	policy, err := PolicyFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := policy.rules["delivery.email"]; rule.Role != auth.RoleViewer || rule.MaskName != "full" {
+		t.Errorf("rule of delivery.email = %v:%s, want viewer:full", rule.Role, rule.MaskName)
+	}
+
+	t.Setenv("MASKING_RULES", "delivery.email=viewer:blur")
+	if _, err := PolicyFromEnv(); !errors.Is(err, ErrUnknownMask) {
+		t.Errorf("PolicyFromEnv = %v, want ErrUnknownMask", err)
+	}
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"test-task/internal/cache"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolAcquiredDesc = prometheus.NewDesc(namespace+"_db_pool_acquired_connections",
+		"Connections currently in use.", nil, nil)
+	poolIdleDesc = prometheus.NewDesc(namespace+"_db_pool_idle_connections",
+		"Idle connections in the pool.", nil, nil)
+	poolTotalDesc = prometheus.NewDesc(namespace+"_db_pool_total_connections",
+		"Total connections in the pool.", nil, nil)
+	poolMaxDesc = prometheus.NewDesc(namespace+"_db_pool_max_connections",
+		"Maximum size of the pool.", nil, nil)
+	poolAcquireCountDesc = prometheus.NewDesc(namespace+"_db_pool_acquire_total",
+		"Successful connection acquires from the pool.", nil, nil)
+	poolAcquireDurationDesc = prometheus.NewDesc(namespace+"_db_pool_acquire_duration_seconds_total",
+		"Total time spent acquiring connections.", nil, nil)
+	poolEmptyAcquireDesc = prometheus.NewDesc(namespace+"_db_pool_empty_acquire_total",
+		"Acquires that had to wait for a connection.", nil, nil)
+
+	cacheHitsDesc = prometheus.NewDesc(namespace+"_cache_hits_total",
+		"Order cache hits.", nil, nil)
+	cacheStaleHitsDesc = prometheus.NewDesc(namespace+"_cache_stale_hits_total",
+		"Order cache hits on entries older than the TTL.", nil, nil)
+	cacheNegativeHitsDesc = prometheus.NewDesc(namespace+"_cache_negative_hits_total",
+		"Lookups answered by a remembered missing order.", nil, nil)
+	cacheCoalescedDesc = prometheus.NewDesc(namespace+"_cache_coalesced_total",
+		"Lookups that waited for a load already in flight.", nil, nil)
+	cacheMissesDesc = prometheus.NewDesc(namespace+"_cache_misses_total",
+		"Order cache misses.", nil, nil)
+	cacheEvictionsDesc = prometheus.NewDesc(namespace+"_cache_evictions_total",
+		"Orders evicted from the cache.", nil, nil)
+	cacheSizeDesc = prometheus.NewDesc(namespace+"_cache_size",
+		"Orders currently cached.", nil, nil)
+	cacheL2HitsDesc = prometheus.NewDesc(namespace+"_cache_l2_hits_total",
+		"Orders found in the second-level cache.", nil, nil)
+	cacheL2MissesDesc = prometheus.NewDesc(namespace+"_cache_l2_misses_total",
+		"Orders missing from the second-level cache.", nil, nil)
+	cacheL2ErrorsDesc = prometheus.NewDesc(namespace+"_cache_l2_errors_total",
+		"Failed second-level cache calls.", nil, nil)
+)
+
+type poolCollector struct {
+	stat func() *pgxpool.Stat
+}
+
+// NewPoolCollector exports pgx pool statistics read on every scrape.
+func NewPoolCollector(stat func() *pgxpool.Stat) prometheus.Collector {
+	return &poolCollector{stat: stat}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredDesc
+	ch <- poolIdleDesc
+	ch <- poolTotalDesc
+	ch <- poolMaxDesc
+	ch <- poolAcquireCountDesc
+	ch <- poolAcquireDurationDesc
+	ch <- poolEmptyAcquireDesc
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.stat()
+	if stat == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(poolAcquiredDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalDesc, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxDesc, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireCountDesc, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDurationDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquireDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+}
+
+type cacheCollector struct {
+	stats func() cache.Stats
+}
+
+// NewCacheCollector exports order cache statistics read on every scrape.
+func NewCacheCollector(stats func() cache.Stats) prometheus.Collector {
+	return &cacheCollector{stats: stats}
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheHitsDesc
+	ch <- cacheStaleHitsDesc
+	ch <- cacheNegativeHitsDesc
+	ch <- cacheCoalescedDesc
+	ch <- cacheMissesDesc
+	ch <- cacheEvictionsDesc
+	ch <- cacheSizeDesc
+	ch <- cacheL2HitsDesc
+	ch <- cacheL2MissesDesc
+	ch <- cacheL2ErrorsDesc
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats()
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(cacheStaleHitsDesc, prometheus.CounterValue, float64(stats.StaleHits))
+	ch <- prometheus.MustNewConstMetric(cacheNegativeHitsDesc, prometheus.CounterValue, float64(stats.NegativeHits))
+	ch <- prometheus.MustNewConstMetric(cacheCoalescedDesc, prometheus.CounterValue, float64(stats.Coalesced))
+	ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(cacheSizeDesc, prometheus.GaugeValue, float64(stats.Size))
+	ch <- prometheus.MustNewConstMetric(cacheL2HitsDesc, prometheus.CounterValue, float64(stats.L2Hits))
+	ch <- prometheus.MustNewConstMetric(cacheL2MissesDesc, prometheus.CounterValue, float64(stats.L2Misses))
+	ch <- prometheus.MustNewConstMetric(cacheL2ErrorsDesc, prometheus.CounterValue, float64(stats.L2Errors))
+}
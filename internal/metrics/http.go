@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"test-task/internal/httpserver"
+
+	"github.com/gorilla/mux"
+)
+
+// HTTPMiddleware measures request latency labelled by the mux route template,
+// so order ids do not end up in label values.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := httpserver.NewStatusRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		httpRequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(rec.Status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
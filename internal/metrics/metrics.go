@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "orders"
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	kafkaConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_consumed_total",
+		Help:      "Kafka messages consumed by topic.",
+	}, []string{"topic"})
+
+	kafkaProduced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_produced_total",
+		Help:      "Kafka messages produced by topic.",
+	}, []string{"topic"})
+
+	kafkaFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_failed_total",
+		Help:      "Kafka messages failed to produce or process by topic.",
+	}, []string{"topic", "stage"})
+
+	kafkaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Messages between the last consumed offset and the partition high water mark.",
+	}, []string{"topic", "partition"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Repository operation latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "result"})
+
+	auditDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "audit",
+		Name:      "entries_dropped_total",
+		Help:      "Audit entries lost because the queue was full or the store failed.",
+	})
+)
+
+const (
+	StageProduce = "produce"
+	StageProcess = "process"
+)
+
+func KafkaConsumed(topic string) {
+	kafkaConsumed.WithLabelValues(topic).Inc()
+}
+
+func KafkaProduced(topic string) {
+	kafkaProduced.WithLabelValues(topic).Inc()
+}
+
+func KafkaFailed(topic string, stage string) {
+	kafkaFailed.WithLabelValues(topic, stage).Inc()
+}
+
+func KafkaLag(topic string, partition int32, lag int64) {
+	kafkaLag.WithLabelValues(topic, strconv.Itoa(int(partition))).Set(float64(lag))
+}
+
+func AuditDropped(count int) {
+	auditDropped.Add(float64(count))
+}
+
+// ObserveQuery records the latency of a repository operation. Use with
+// defer and a pointer to the named error result:
+//
+//	defer metrics.ObserveQuery("insert_order", time.Now(), &err)
+func ObserveQuery(operation string, start time.Time, err *error) {
+	result := "ok"
+	if err != nil && *err != nil {
+		result = "error"
+	}
+	dbQueryDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// AuditAction is what an actor did with an order.
+type AuditAction string
+
+const (
+	// AuditRead is a lookup of a single order.
+	AuditRead   AuditAction = "read"
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	// AuditDelete is the removal of a stored copy of an order, orders
+	// themselves are never deleted.
+	AuditDelete AuditAction = "delete"
+	// AuditExport is a list or a search returning orders in bulk. It is
+	// recorded once per request with the count and the filter in the details.
+	AuditExport AuditAction = "export"
+)
+
+// AuditOutcome is how the action ended.
+type AuditOutcome string
+
+const (
+	OutcomeSuccess  AuditOutcome = "success"
+	OutcomeNotFound AuditOutcome = "not_found"
+	OutcomeDenied   AuditOutcome = "denied"
+	OutcomeRejected AuditOutcome = "rejected"
+	OutcomeError    AuditOutcome = "error"
+)
+
+// AuditEntry is a record of the append-only audit log. RequestID links the
+// entries recorded for one request, Details describe actions without a
+// single order, such as an export.
+type AuditEntry struct {
+	ID        int64        `json:"id" db:"id"`
+	Actor     string       `json:"actor" db:"actor"`
+	Action    AuditAction  `json:"action" db:"action"`
+	OrderUID  string       `json:"order_uid" db:"order_uid"`
+	Source    string       `json:"source" db:"source"`
+	Outcome   AuditOutcome `json:"outcome" db:"outcome"`
+	RequestID string       `json:"request_id,omitempty" db:"request_id"`
+	Details   string       `json:"details,omitempty" db:"details"`
+	Timestamp time.Time    `json:"timestamp" db:"created_at"`
+}
+
+// AuditFilter selects audit entries by order or actor, newest first.
+type AuditFilter struct {
+	OrderUID string
+	Actor    string
+	// Before returns entries with a smaller id, for paging.
+	Before int64
+	Limit  int
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// OrderSummary is an order in a list, without personal data and items.
+type OrderSummary struct {
+	OrderUID        string      `json:"order_uid" db:"order_uid"`
+	TrackNumber     string      `json:"track_number" db:"track_number"`
+	Status          OrderStatus `json:"status" db:"status"`
+	DeliveryService string      `json:"delivery_service" db:"delivery_service"`
+	DateCreated     time.Time   `json:"date_created" db:"date_created"`
+	Amount          float64     `json:"amount" db:"amount"`
+	Currency        string      `json:"currency" db:"currency"`
+	ItemCount       int         `json:"item_count" db:"item_count"`
+}
+
+func (o Order) Summary() OrderSummary {
+	return OrderSummary{
+		OrderUID:        o.OrderUID,
+		TrackNumber:     o.TrackNumber,
+		Status:          o.Status,
+		DeliveryService: o.DeliveryService,
+		DateCreated:     o.DateCreated,
+		Amount:          o.Payment.Amount,
+		Currency:        o.Payment.Currency,
+		ItemCount:       len(o.Items),
+	}
+}
+
+// OrderFilter selects orders for a list, newest first.
+type OrderFilter struct {
+	TrackNumber string
+	// CustomerID restricts the list to the orders of one customer.
+	CustomerID string
+	// After continues the list after the order with this id, for paging.
+	After string
+	Limit int
+}
@@ -5,20 +5,22 @@ import (
 )
 
 type Order struct {
-	OrderUID          string    `json:"order_uid" fake:"{uuid}"`
-	TrackNumber       string    `json:"track_number"`
-	Entry             string    `json:"entry"`
-	Delivery          Delivery  `json:"delivery" fake:"skip"`
-	Payment           Payment   `json:"payment" fake:"skip"`
-	Items             []Item    `json:"items" fake:"skip"`
-	Locale            string    `json:"locale" fake:"{languageabbreviation}"`
-	InternalSignature string    `json:"internal_signature" fake:"skip"`
-	CustomerID        string    `json:"customer_id" fake:"{uuid}""`
-	DeliveryService   string    `json:"delivery_service" fake:"{company}"`
-	Shardkey          string    `json:"shardkey"`
-	SmID              int       `json:"sm_id" fake:"{number:1,100}"`
-	DateCreated       time.Time `json:"date_created"`
-	OofShard          string    `json:"oof_shard"`
+	OrderUID          string      `json:"order_uid" fake:"{uuid}"`
+	TrackNumber       string      `json:"track_number"`
+	Entry             string      `json:"entry"`
+	Delivery          Delivery    `json:"delivery" fake:"skip"`
+	Payment           Payment     `json:"payment" fake:"skip"`
+	Items             []Item      `json:"items" fake:"skip"`
+	Locale            string      `json:"locale" fake:"{languageabbreviation}"`
+	InternalSignature string      `json:"internal_signature" fake:"skip"`
+	CustomerID        string      `json:"customer_id" fake:"{uuid}"`
+	DeliveryService   string      `json:"delivery_service" fake:"{company}"`
+	Shardkey          string      `json:"shardkey"`
+	SmID              int         `json:"sm_id" fake:"{number:1,100}"`
+	DateCreated       time.Time   `json:"date_created"`
+	OofShard          string      `json:"oof_shard"`
+	Status            OrderStatus `json:"status" fake:"skip"`
+	Version           int         `json:"version" fake:"skip"`
 }
 
 type Delivery struct {
@@ -49,7 +51,7 @@ type Payment struct {
 type Item struct {
 	ID          int     `json:"-"`
 	OrderUID    string  `json:"-"`
-	ChrtID      int64   `json:"chrt_id"fake:"{number:1,10000}"`
+	ChrtID      int64   `json:"chrt_id" fake:"{number:1,10000}"`
 	TrackNumber string  `json:"track_number" `
 	Price       int     `json:"price" fake:"{number:1000,10000}"`
 	Rid         string  `json:"rid" fake:"{uuid}"`
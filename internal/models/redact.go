@@ -0,0 +1,41 @@
+package models
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// LogValue hides personal data of the recipient when a delivery is logged.
+func (d Delivery) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("name", Redact(d.Name)),
+		slog.String("phone", Redact(d.Phone)),
+		slog.String("zip", Redact(d.Zip)),
+		slog.String("city", d.City),
+		slog.String("address", Redact(d.Address)),
+		slog.String("region", d.Region),
+		slog.String("email", Redact(d.Email)),
+	)
+}
+
+// LogValue logs an order with its redacted delivery and the item count.
+func (o Order) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("order_uid", o.OrderUID),
+		slog.String("track_number", o.TrackNumber),
+		slog.String("customer_id", o.CustomerID),
+		slog.String("status", string(o.Status)),
+		slog.Int("version", o.Version),
+		slog.Any("delivery", o.Delivery),
+		slog.Int("items", len(o.Items)),
+	)
+}
+
+// Redact keeps only the first and last characters of a value.
+func Redact(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 2 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+}
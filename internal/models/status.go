@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type OrderStatus string
+
+const (
+	StatusCreated   OrderStatus = "created"
+	StatusPaid      OrderStatus = "paid"
+	StatusAssembled OrderStatus = "assembled"
+	StatusShipped   OrderStatus = "shipped"
+	StatusDelivered OrderStatus = "delivered"
+	StatusCancelled OrderStatus = "cancelled"
+	StatusReturned  OrderStatus = "returned"
+)
+
+var ErrUnknownStatus = errors.New("unknown order status")
+
+// transitions lists the statuses an order may move to from each status.
+// Statuses without entries are final.
+var transitions = map[OrderStatus][]OrderStatus{
+	StatusCreated:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusAssembled, StatusCancelled},
+	StatusAssembled: {StatusShipped, StatusCancelled},
+	StatusShipped:   {StatusDelivered, StatusReturned},
+	StatusDelivered: {StatusReturned},
+}
+
+// StatusChange is stored in the status history and published as an event
+// on each transition.
+type StatusChange struct {
+	OrderUID   string      `json:"order_uid"`
+	FromStatus OrderStatus `json:"from_status"`
+	ToStatus   OrderStatus `json:"to_status"`
+	Reason     string      `json:"reason,omitempty"`
+	ChangedAt  time.Time   `json:"changed_at"`
+}
+
+// StatusRequest is the payload of the status change command.
+type StatusRequest struct {
+	OrderUID string      `json:"order_uid"`
+	Status   OrderStatus `json:"status"`
+	Reason   string      `json:"reason,omitempty"`
+}
+
+func ParseOrderStatus(s string) (OrderStatus, error) {
+	switch status := OrderStatus(s); status {
+	case StatusCreated, StatusPaid, StatusAssembled, StatusShipped,
+		StatusDelivered, StatusCancelled, StatusReturned:
+		return status, nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownStatus, s)
+}
+
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package models
+
+// DeliveryPatch holds the delivery fields to change. Nil fields are left as is.
+type DeliveryPatch struct {
+	Name    *string `json:"name,omitempty"`
+	Phone   *string `json:"phone,omitempty"`
+	Zip     *string `json:"zip,omitempty"`
+	City    *string `json:"city,omitempty"`
+	Address *string `json:"address,omitempty"`
+	Region  *string `json:"region,omitempty"`
+	Email   *string `json:"email,omitempty"`
+}
+
+// ItemStatusPatch sets the status of the item identified by chrt_id and rid.
+type ItemStatusPatch struct {
+	ChrtID int64  `json:"chrt_id"`
+	Rid    string `json:"rid"`
+	Status int    `json:"status"`
+}
+
+// OrderUpdate is the payload of the partial update command. Version must
+// match the current order version, zero skips the check. An update without
+// changes leaves the order and its version as they are.
+type OrderUpdate struct {
+	OrderUID string            `json:"order_uid"`
+	Version  int               `json:"version,omitempty"`
+	Delivery *DeliveryPatch    `json:"delivery,omitempty"`
+	Items    []ItemStatusPatch `json:"items,omitempty"`
+}
+
+// Empty reports whether the update changes nothing.
+func (update OrderUpdate) Empty() bool {
+	return (update.Delivery == nil || update.Delivery.Empty()) && len(update.Items) == 0
+}
+
+func (patch *DeliveryPatch) Empty() bool {
+	return patch.Name == nil && patch.Phone == nil && patch.Zip == nil && patch.City == nil &&
+		patch.Address == nil && patch.Region == nil && patch.Email == nil
+}
+
+func (patch *DeliveryPatch) Apply(delivery *Delivery) {
+	fields := []struct {
+		value *string
+		dst   *string
+	}{
+		{patch.Name, &delivery.Name},
+		{patch.Phone, &delivery.Phone},
+		{patch.Zip, &delivery.Zip},
+		{patch.City, &delivery.City},
+		{patch.Address, &delivery.Address},
+		{patch.Region, &delivery.Region},
+		{patch.Email, &delivery.Email},
+	}
+	for _, f := range fields {
+		if f.value != nil {
+			*f.dst = *f.value
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package openapi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"test-task/internal/cache"
+	models "test-task/internal/models"
+)
+
+type builder struct {
+	schemas map[string]interface{}
+	named   map[reflect.Type]map[string]interface{}
+}
+
+var (
+	specOnce sync.Once
+	specJSON []byte
+)
+
+// Spec returns the OpenAPI 3 document describing the HTTP API.
+func Spec() map[string]interface{} {
+	b := &builder{
+		schemas: map[string]interface{}{},
+		named: map[reflect.Type]map[string]interface{}{
+			reflect.TypeOf(models.OrderStatus("")): {
+				"type": "string",
+				"enum": []models.OrderStatus{
+					models.StatusCreated, models.StatusPaid, models.StatusAssembled,
+					models.StatusShipped, models.StatusDelivered,
+					models.StatusCancelled, models.StatusReturned,
+				},
+			},
+		},
+	}
+
+	order := b.schemaOf(reflect.TypeOf(models.Order{}))
+	orderUID := map[string]interface{}{
+		"name":     "order_uid",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string"},
+	}
+
+	paths := map[string]interface{}{
+		"/": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "Web UI",
+				"responses": map[string]interface{}{
+					"200": contentResponse("HTML page", "text/html", map[string]interface{}{"type": "string"}),
+				},
+			},
+		},
+		"/add": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "Create random orders",
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Created orders", b.schemaOf(reflect.TypeOf([]models.Order{}))),
+					"502": textResponse("The order service failed or did not reply"),
+					"503": textResponse("Kafka is not connected"),
+				},
+			},
+		},
+		"/order/{order_uid}": map[string]interface{}{
+			"parameters": []interface{}{orderUID},
+			"get": map[string]interface{}{
+				"summary": "Get order by id",
+				"parameters": []interface{}{
+					headerParameter("If-None-Match", "ETag of the cached order"),
+				},
+				"responses": map[string]interface{}{
+					"200": withStale(withETag(jsonResponse("Order", order))),
+					"304": withETag(map[string]interface{}{"description": "Order is not modified"}),
+					"404": textResponse("Order does not exist"),
+					"500": textResponse("Internal error"),
+					"503": textResponse("Database is unavailable and the order is not cached"),
+				},
+			},
+			"patch": map[string]interface{}{
+				"summary": "Update delivery fields and item statuses",
+				"parameters": []interface{}{
+					headerParameter("If-Match", "ETag of the order version being updated, or * to update "+
+						"any version. Required unless the body has the version. Weak ETags never match"),
+				},
+				"requestBody": jsonBody(b.schemaOf(reflect.TypeOf(models.OrderUpdate{}))),
+				"responses": map[string]interface{}{
+					"200": withETag(jsonResponse("Updated order", order)),
+					"400": textResponse("Invalid request"),
+					"404": textResponse("Order or item does not exist"),
+					"412": textResponse("Order version conflict or weak ETag"),
+					"428": textResponse("Neither If-Match nor version is given"),
+					"500": textResponse("Internal error"),
+				},
+			},
+		},
+		"/order/{order_uid}/status": map[string]interface{}{
+			"parameters": []interface{}{orderUID},
+			"get": map[string]interface{}{
+				"summary": "Get order status history",
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Status history", b.schemaOf(reflect.TypeOf([]models.StatusChange{}))),
+					"404": textResponse("Order does not exist"),
+					"500": textResponse("Internal error"),
+				},
+			},
+			"post": map[string]interface{}{
+				"summary":     "Change order status",
+				"requestBody": jsonBody(b.schemaOf(reflect.TypeOf(models.StatusRequest{}))),
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Status change", b.schemaOf(reflect.TypeOf(models.StatusChange{}))),
+					"400": textResponse("Invalid request or unknown status"),
+					"404": textResponse("Order does not exist"),
+					"409": textResponse("Status transition is not allowed"),
+					"500": textResponse("Internal error"),
+				},
+			},
+		},
+	}
+
+	stats := b.schemaOf(reflect.TypeOf(cache.Stats{}))
+
+	paths["/admin/cache/keys"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary": "List cached orders from the most to the least recently used",
+			"responses": map[string]interface{}{
+				"200": jsonResponse("Cached orders", b.schemaOf(reflect.TypeOf([]cache.KeyInfo{}))),
+			},
+		},
+		"delete": map[string]interface{}{
+			"summary": "Flush the cache",
+			"responses": map[string]interface{}{
+				"204": map[string]interface{}{"description": "Cache is flushed"},
+			},
+		},
+	}
+	paths["/admin/cache/keys/{order_uid}"] = map[string]interface{}{
+		"parameters": []interface{}{orderUID},
+		"delete": map[string]interface{}{
+			"summary": "Evict an order from the cache",
+			"responses": map[string]interface{}{
+				"204": map[string]interface{}{"description": "Order is evicted"},
+				"404": textResponse("Order is not cached"),
+			},
+		},
+	}
+	paths["/admin/cache/stats"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary": "Cache statistics",
+			"responses": map[string]interface{}{
+				"200": jsonResponse("Cache statistics", stats),
+			},
+		},
+	}
+	paths["/admin/cache/warm"] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"summary": "Load uncached orders from the database into the free cache slots",
+			"responses": map[string]interface{}{
+				"200": jsonResponse("Number of loaded orders", map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"loaded": map[string]interface{}{"type": "integer"}},
+				}),
+				"500": textResponse("Internal error"),
+			},
+		},
+	}
+	paths["/admin/cache/capacity"] = map[string]interface{}{
+		"put": map[string]interface{}{
+			"summary": "Change the cache capacity",
+			"requestBody": jsonBody(map[string]interface{}{
+				"type":       "object",
+				"required":   []string{"capacity"},
+				"properties": map[string]interface{}{"capacity": map[string]interface{}{"type": "integer", "minimum": 1}},
+			}),
+			"responses": map[string]interface{}{
+				"200": jsonResponse("Cache statistics", stats),
+				"400": textResponse("Invalid capacity"),
+			},
+		},
+	}
+
+	paths["/orders"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary": "Recent orders, newest first; customers see their own orders only",
+			"parameters": []interface{}{
+				queryParameter("track_number", "Return the orders with this track number"),
+				queryParameter("after", "Continue after the order with this id, for paging"),
+				queryParameter("limit", "Maximum number of orders, 20 by default and at most 100"),
+			},
+			"responses": map[string]interface{}{
+				"200": jsonResponse("Order summaries", b.schemaOf(reflect.TypeOf([]models.OrderSummary{}))),
+				"400": textResponse("Invalid limit"),
+				"500": textResponse("Internal error"),
+			},
+		},
+	}
+	paths["/orders/feed"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary": "Server-Sent Events stream of new orders; customers receive their own orders only",
+			"description": "Each order event has the id of the event and an order summary as data. " +
+				"A reconnecting client passes the last id received to resume the stream.",
+			"parameters": []interface{}{
+				queryParameter("delivery_service", "Stream the orders of this delivery service"),
+				queryParameter("customer_id", "Stream the orders of this customer"),
+				headerParameter("Last-Event-ID", "Resume after this event"),
+				queryParameter("last_event_id", "Resume after this event, for clients that cannot set headers"),
+			},
+			"responses": map[string]interface{}{
+				"200": contentResponse("Event stream of order summaries", "text/event-stream", map[string]interface{}{"type": "string"}),
+				"400": textResponse("Invalid Last-Event-ID"),
+				"503": textResponse("Too many open streams or the service is shutting down"),
+			},
+		},
+	}
+	paths["/orders/search"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary": "Find orders by the exact delivery phone or email",
+			"parameters": []interface{}{
+				queryParameter("phone", "Delivery phone, only digits are compared"),
+				queryParameter("email", "Delivery email, compared case-insensitively"),
+			},
+			"responses": map[string]interface{}{
+				"200": jsonResponse("Orders", b.schemaOf(reflect.TypeOf([]models.Order{}))),
+				"400": textResponse("Neither or both of phone and email are given"),
+				"500": textResponse("Internal error"),
+			},
+		},
+	}
+	paths["/admin/encryption/reencrypt"] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"summary": "Re-encrypt deliveries with the primary key",
+			"responses": map[string]interface{}{
+				"200": jsonResponse("Number of re-encrypted deliveries", map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"reencrypted": map[string]interface{}{"type": "integer"}},
+				}),
+				"409": textResponse("Encryption is not configured"),
+				"500": textResponse("Internal error"),
+			},
+		},
+	}
+	paths["/admin/audit"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary": "Audit entries of an order or an actor, newest first",
+			"parameters": []interface{}{
+				queryParameter("order_uid", "Order the entries refer to"),
+				queryParameter("actor", "Subject of the caller, kafka for Kafka commands"),
+				queryParameter("before", "Return entries with a smaller id, for paging"),
+				queryParameter("limit", "Maximum number of entries, 100 by default and at most 1000"),
+			},
+			"responses": map[string]interface{}{
+				"200": jsonResponse("Audit entries", b.schemaOf(reflect.TypeOf([]models.AuditEntry{}))),
+				"400": textResponse("Neither order_uid nor actor is given, or a parameter is invalid"),
+				"500": textResponse("Internal error"),
+			},
+		},
+	}
+
+	// Every route but the UI requires credentials, see auth.Require, and is
+	// rate limited per client.
+	security := []interface{}{
+		map[string]interface{}{"bearerAuth": []string{}},
+		map[string]interface{}{"apiKey": []string{}},
+	}
+	for path, item := range paths {
+		for method, operation := range item.(map[string]interface{}) {
+			if method == "parameters" {
+				continue
+			}
+			operation := operation.(map[string]interface{})
+			responses := operation["responses"].(map[string]interface{})
+			responses["429"] = withHeader(textResponse("Client rate limit is exceeded, or too many failed authentications"),
+				"Retry-After", "Seconds until the next request is allowed")
+			if path == "/" {
+				continue
+			}
+			operation["security"] = security
+			responses["401"] = textResponse("Missing or invalid credentials")
+			responses["403"] = textResponse("Role does not allow the operation")
+			if _, ok := operation["requestBody"]; ok {
+				responses["413"] = textResponse("Request body is too large")
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Orders service",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "JWT or static API key",
+				},
+				"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+}
+
+// Handler serves the OpenAPI document as JSON.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	specOnce.Do(func() {
+		var err error
+		specJSON, err = json.MarshalIndent(Spec(), "", "  ")
+		if err != nil {
+			slog.Error("Failed to create OpenAPI document", "error", err)
+		}
+	})
+	if specJSON == nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(specJSON)
+}
+
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return contentResponse(description, "application/json", schema)
+}
+
+func textResponse(description string) map[string]interface{} {
+	return contentResponse(description, "text/plain", map[string]interface{}{"type": "string"})
+}
+
+func contentResponse(description string, contentType string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			contentType: map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+func jsonBody(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+func headerParameter(name string, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "header",
+		"description": description,
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+}
+
+func queryParameter(name string, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+}
+
+func withETag(response map[string]interface{}) map[string]interface{} {
+	return withHeader(response, "ETag", "Order version")
+}
+
+func withStale(response map[string]interface{}) map[string]interface{} {
+	return withHeader(response, "X-Stale", "Set to true when the order is served from the cache while the database is unavailable")
+}
+
+func withHeader(response map[string]interface{}, name string, description string) map[string]interface{} {
+	headers, _ := response["headers"].(map[string]interface{})
+	if headers == nil {
+		headers = map[string]interface{}{}
+		response["headers"] = headers
+	}
+	headers[name] = map[string]interface{}{
+		"description": description,
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+	return response
+}
@@ -0,0 +1,205 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"test-task/internal/auth"
+	"test-task/internal/httpserver"
+	"test-task/internal/logger"
+)
+
+// sweepInterval is how often idle buckets are dropped so the number of
+// tracked clients stays bounded.
+const sweepInterval = time.Minute
+
+var ErrInvalidLimit = errors.New("invalid rate limit")
+
+// Limit allows Rate requests per second on average with bursts of Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses "N/unit[,burst]" where unit is s, m or h, for example
+// "10/s" or "60/m,10". The burst defaults to N rounded up.
+func ParseLimit(value string) (Limit, error) {
+	spec, burstValue, hasBurst := strings.Cut(strings.TrimSpace(value), ",")
+	countValue, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, value)
+	}
+	count, err := strconv.ParseFloat(countValue, 64)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, value)
+	}
+
+	var period time.Duration
+	switch unit {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("%w: unknown unit in %q", ErrInvalidLimit, value)
+	}
+
+	limit := Limit{Rate: count / period.Seconds(), Burst: int(math.Ceil(count))}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burstValue); err != nil || limit.Burst < 1 {
+			return Limit{}, fmt.Errorf("%w: bad burst in %q", ErrInvalidLimit, value)
+		}
+	}
+	return limit, nil
+}
+
+// LimitFromEnv reads the limit from the variable, falling back to def.
+func LimitFromEnv(name string, def Limit) (Limit, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	limit, err := ParseLimit(value)
+	if err != nil {
+		return Limit{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return limit, nil
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter keeps a token bucket per client.
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func New(limit Limit) *Limiter {
+	return &Limiter{limit: limit, buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// Allow takes a token from the bucket of the client. When the bucket is
+// empty it returns false and how long until the next token.
+func (limiter *Limiter) Allow(key string) (bool, time.Duration) {
+	return limiter.take(key, true)
+}
+
+// Available reports whether the bucket of the client has a token without
+// taking it, and otherwise how long until the next one.
+func (limiter *Limiter) Available(key string) (bool, time.Duration) {
+	return limiter.take(key, false)
+}
+
+func (limiter *Limiter) take(key string, consume bool) (bool, time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(limiter.lastSweep) > sweepInterval {
+		limiter.sweep(now)
+	}
+
+	b, ok := limiter.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limiter.limit.Burst), updated: now}
+		limiter.buckets[key] = b
+	}
+	limiter.refill(b, now)
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / limiter.limit.Rate * float64(time.Second))
+		return false, wait
+	}
+	if consume {
+		b.tokens--
+	}
+	return true, 0
+}
+
+func (limiter *Limiter) refill(b *bucket, now time.Time) {
+	b.tokens = math.Min(float64(limiter.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limiter.limit.Rate)
+	b.updated = now
+}
+
+// sweep drops the buckets that are full again, they behave like new ones.
+func (limiter *Limiter) sweep(now time.Time) {
+	for key, b := range limiter.buckets {
+		limiter.refill(b, now)
+		if b.tokens >= float64(limiter.limit.Burst) {
+			delete(limiter.buckets, key)
+		}
+	}
+	limiter.lastSweep = now
+}
+
+// Middleware rejects requests over the limit of their client with 429 and
+// a Retry-After header.
+func (limiter *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := ClientKey(r)
+		if ok, wait := limiter.Allow(key); !ok {
+			reject(w, r, key, wait)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// FailureMiddleware throttles authentication failures by IP address: every
+// 401 response takes a token, and a client without tokens is rejected before
+// its credentials are checked. It wraps the authentication middleware, so
+// credential guessing is slowed down while valid callers are not limited.
+func (limiter *Limiter) FailureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "ip:" + remoteIP(r)
+		if ok, wait := limiter.Available(key); !ok {
+			reject(w, r, key, wait)
+			return
+		}
+		rec := httpserver.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+		if rec.Status == http.StatusUnauthorized {
+			limiter.Allow(key)
+		}
+	})
+}
+
+func reject(w http.ResponseWriter, r *http.Request, key string, wait time.Duration) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	logger.FromContext(r.Context()).Warn("Rate limit exceeded", "client", key, "path", r.URL.Path)
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	http.Error(w, "too many requests", http.StatusTooManyRequests)
+}
+
+// ClientKey identifies the caller: the authenticated subject, or the remote
+// IP address for anonymous requests.
+func ClientKey(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok && principal.Subject != "" && principal.Subject != auth.AnonymousSubject {
+		return "sub:" + principal.Subject
+	}
+	return "ip:" + remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFailureMiddlewareThrottlesOnlyFailures(t *testing.T) {
+	limiter := New(Limit{Rate: 0.001, Burst: 2})
+	handler := limiter.FailureMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}
+	}))
+
+	do := func(remoteAddr string, token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 5; i++ {
+		if status := do("10.0.0.1:1000", "valid"); status != http.StatusOK {
+			t.Fatalf("valid request %d = %d, want 200", i, status)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if status := do("10.0.0.1:1000", "guess"); status != http.StatusUnauthorized {
+			t.Fatalf("failure %d = %d, want 401", i, status)
+		}
+	}
+	if status := do("10.0.0.1:1001", "guess"); status != http.StatusTooManyRequests {
+		t.Fatalf("guess after the burst = %d, want 429", status)
+	}
+	if status := do("10.0.0.2:1000", "guess"); status != http.StatusUnauthorized {
+		t.Fatalf("guess from another address = %d, want 401", status)
+	}
+}
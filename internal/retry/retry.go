@@ -0,0 +1,112 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Backoff describes exponential backoff with jitter. MaxAttempts of zero
+// retries until the context is cancelled.
+type Backoff struct {
+	Initial     time.Duration
+	Max         time.Duration
+	Multiplier  float64
+	Jitter      float64
+	MaxAttempts int
+}
+
+func DefaultBackoff() Backoff {
+	return Backoff{
+		Initial:     500 * time.Millisecond,
+		Max:         30 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+		MaxAttempts: 0,
+	}
+}
+
+// BackoffFromEnv overrides the defaults with RETRY_INITIAL_INTERVAL,
+// RETRY_MAX_INTERVAL (durations like 500ms or 10s) and RETRY_MAX_ATTEMPTS.
+func BackoffFromEnv() Backoff {
+	backoff := DefaultBackoff()
+	if d, err := time.ParseDuration(os.Getenv("RETRY_INITIAL_INTERVAL")); err == nil && d > 0 {
+		backoff.Initial = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("RETRY_MAX_INTERVAL")); err == nil && d > 0 {
+		backoff.Max = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("RETRY_MAX_ATTEMPTS")); err == nil && n >= 0 {
+		backoff.MaxAttempts = n
+	}
+	return backoff
+}
+
+// Delay returns the wait before the given retry attempt, starting from 1.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Initial)
+	for i := 1; i < attempt; i++ {
+		delay *= b.Multiplier
+		if delay >= float64(b.Max) {
+			delay = float64(b.Max)
+			break
+		}
+	}
+	if b.Jitter > 0 {
+		delay += delay * b.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// permanentError is an error that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not retryable, such as a configuration or
+// authentication error. Do returns it at once without the mark.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Do calls fn until it succeeds, returns a permanent error, the attempts are
+// exhausted or ctx is done.
+func Do(ctx context.Context, b Backoff, name string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			if attempt > 1 {
+				slog.Info("Connected after retries", "dependency", name, "attempts", attempt)
+			}
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if b.MaxAttempts > 0 && attempt >= b.MaxAttempts {
+			return err
+		}
+
+		delay := b.Delay(attempt)
+		slog.Warn("Dependency is not available, retrying", "dependency", name,
+			"attempt", attempt, "retry_in", delay.String(), "error", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
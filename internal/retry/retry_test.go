@@ -0,0 +1,104 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDelayBounds(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2, Jitter: 0.2}
+
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+	for _, tt := range tests {
+		low := time.Duration(float64(tt.base) * (1 - b.Jitter))
+		high := time.Duration(float64(tt.base) * (1 + b.Jitter))
+		for i := 0; i < 100; i++ {
+			if delay := b.Delay(tt.attempt); delay < low || delay > high {
+				t.Fatalf("Delay(%d) = %v, want within [%v, %v]", tt.attempt, delay, low, high)
+			}
+		}
+	}
+
+	b.Jitter = 0
+	if delay := b.Delay(3); delay != 400*time.Millisecond {
+		t.Errorf("Delay(3) without jitter = %v, want 400ms", delay)
+	}
+}
+
+func TestDoRetriesUntilSuccess(t *testing.T) {
+	b := Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 2}
+	calls := 0
+	err := Do(context.Background(), b, "test", func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Do = %v after %d calls, want success after 3", err, calls)
+	}
+}
+
+func TestDoStopsAfterMaxAttempts(t *testing.T) {
+	b := Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 2, MaxAttempts: 4}
+	unavailable := errors.New("unavailable")
+	calls := 0
+	err := Do(context.Background(), b, "test", func() error {
+		calls++
+		return unavailable
+	})
+	if !errors.Is(err, unavailable) || calls != 4 {
+		t.Errorf("Do = %v after %d calls, want the last error after 4", err, calls)
+	}
+}
+
+func TestDoDoesNotRetryPermanentErrors(t *testing.T) {
+	b := Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 2}
+	denied := errors.New("authentication failed")
+	calls := 0
+	err := Do(context.Background(), b, "test", func() error {
+		calls++
+		return Permanent(denied)
+	})
+	if err != denied || calls != 1 {
+		t.Errorf("Do = %v after %d calls, want the unmarked error after 1", err, calls)
+	}
+
+	if Permanent(nil) != nil {
+		t.Error("Permanent(nil) is not nil")
+	}
+}
+
+func TestDoStopsWhenContextIsDone(t *testing.T) {
+	b := Backoff{Initial: time.Hour, Max: time.Hour, Multiplier: 2}
+	ctx, cancel := context.WithCancel(context.Background())
+	unavailable := errors.New("unavailable")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Do(ctx, b, "test", func() error { return unavailable })
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, unavailable) {
+			t.Errorf("Do = %v, want the last error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Do keeps waiting after the context is cancelled")
+	}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"test-task/internal/metrics"
+	models "test-task/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var auditColumns = []string{"actor", "action", "order_uid", "source", "outcome", "request_id", "details", "created_at"}
+
+// InsertAuditEntries appends a batch of entries to the audit log.
+func (repository *Repository) InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) (err error) {
+	defer metrics.ObserveQuery("insert_audit", time.Now(), &err)
+
+	_, err = repository.pool.CopyFrom(ctx, pgx.Identifier{"audit_log"}, auditColumns,
+		pgx.CopyFromSlice(len(entries), func(i int) ([]interface{}, error) {
+			entry := entries[i]
+			return []interface{}{
+				entry.Actor, string(entry.Action), entry.OrderUID,
+				entry.Source, string(entry.Outcome), entry.RequestID,
+				entry.Details, entry.Timestamp,
+			}, nil
+		}))
+	if err != nil {
+		return fmt.Errorf("copy audit entries: %w", err)
+	}
+	return nil
+}
+
+// GetAuditEntries returns the entries matching the filter, newest first.
+func (repository *Repository) GetAuditEntries(ctx context.Context, filter models.AuditFilter) (entries []models.AuditEntry, err error) {
+	defer metrics.ObserveQuery("get_audit", time.Now(), &err)
+
+	rows, err := repository.pool.Query(ctx, selectAuditEntries,
+		filter.OrderUID, filter.Actor, filter.Before, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	entries, err = pgx.CollectRows(rows, pgx.RowToStructByName[models.AuditEntry])
+	if err != nil {
+		return nil, fmt.Errorf("collect rows: %w", err)
+	}
+	return entries, nil
+}
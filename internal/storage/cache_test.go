@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"test-task/internal/cache"
+	models "test-task/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *cache.Redis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	backend := cache.NewRedis(cache.RedisOptions{Addr: server.Addr(), TTL: time.Minute})
+	t.Cleanup(func() { backend.Close() })
+	return server, backend
+}
+
+func sharedVersion(t *testing.T, backend *cache.Redis, order_uid string) int {
+	t.Helper()
+	order, err := backend.Get(context.Background(), order_uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order == nil {
+		return 0
+	}
+	return order.Version
+}
+
+func TestRefreshCacheDropsSharedEntryOfUncachedOrder(t *testing.T) {
+	ctx := context.Background()
+	_, backend := newTestRedis(t)
+	repository := newTestRepository(t, backend)
+
+	// Another instance cached the order in the shared level only.
+	if err := backend.Set(ctx, &models.Order{OrderUID: "order-1", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	repository.refreshCache(ctx, "order-1")
+
+	if version := sharedVersion(t, backend, "order-1"); version != 0 {
+		t.Fatalf("shared level kept version %d", version)
+	}
+}
+
+func TestRefreshCacheDropsOrderThatFailsToReload(t *testing.T) {
+	ctx := context.Background()
+	_, backend := newTestRedis(t)
+	repository := newTestRepository(t, backend)
+	repository.cache.Add(&models.Order{OrderUID: "order-1", Version: 1})
+
+	repository.refreshCache(ctx, "order-1")
+
+	if repository.cache.Contains("order-1") {
+		t.Error("local level kept the order")
+	}
+	if version := sharedVersion(t, backend, "order-1"); version != 0 {
+		t.Errorf("shared level kept version %d", version)
+	}
+}
+
+func TestInvalidateOrderKeepsSharedEntry(t *testing.T) {
+	ctx := context.Background()
+	_, backend := newTestRedis(t)
+	writer, replica := newTestRepository(t, backend), newTestRepository(t, backend)
+
+	replica.cache.Add(&models.Order{OrderUID: "order-1", Version: 1})
+	// The writer stores the new version in both levels.
+	writer.cache.Add(&models.Order{OrderUID: "order-1", Version: 2})
+
+	replica.InvalidateOrder(ctx, "order-1", 2)
+
+	if replica.cache.Contains("order-1") {
+		t.Fatal("replica kept the old version")
+	}
+	if version := sharedVersion(t, backend, "order-1"); version != 2 {
+		t.Fatalf("shared version = %d, want 2", version)
+	}
+
+	// The next read is served by the shared level, not the database.
+	order, _, err := replica.cache.GetOrLoad("order-1", func() (*models.Order, error) {
+		t.Error("order is loaded from the database")
+		return nil, nil
+	})
+	if err != nil || order == nil || order.Version != 2 {
+		t.Fatalf("GetOrLoad = %v, %v, want version 2", order, err)
+	}
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"test-task/internal/encryption"
+	"test-task/internal/logger"
+	"test-task/internal/metrics"
+	models "test-task/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	reencryptBatchSize = 100
+	reencryptInterval  = time.Hour
+
+	// searchLimit bounds the orders returned by a contact lookup.
+	searchLimit = 50
+)
+
+var ErrEncryptionDisabled = errors.New("encryption is not configured")
+
+// storedDelivery is a delivery row as kept in the database. With a keyring
+// the personal fields are encrypted with a data key of the row, stored
+// wrapped by the key KeyID, and phone and email get blind indexes.
+type storedDelivery struct {
+	models.Delivery
+	KeyID      *string
+	DataKey    []byte
+	PhoneIndex []byte
+	EmailIndex []byte
+}
+
+func scanDelivery(row pgx.Row) (stored storedDelivery, err error) {
+	err = row.Scan(
+		&stored.OrderUID, &stored.Name, &stored.Phone,
+		&stored.Zip, &stored.City, &stored.Address,
+		&stored.Region, &stored.Email,
+		&stored.KeyID, &stored.DataKey, &stored.PhoneIndex, &stored.EmailIndex,
+	)
+	return stored, err
+}
+
+// args returns the parameters of insertDelivery and updateDelivery.
+func (stored storedDelivery) args(order_uid string) []interface{} {
+	return []interface{}{
+		order_uid, stored.Name, stored.Phone,
+		stored.Zip, stored.City, stored.Address,
+		stored.Region, stored.Email,
+		stored.KeyID, stored.DataKey, stored.PhoneIndex, stored.EmailIndex,
+	}
+}
+
+// personalFields are the encrypted columns of a delivery.
+func personalFields(delivery *models.Delivery) map[string]*string {
+	return map[string]*string{
+		"name":    &delivery.Name,
+		"phone":   &delivery.Phone,
+		"email":   &delivery.Email,
+		"address": &delivery.Address,
+	}
+}
+
+// sealDelivery encrypts the personal fields with a new data key. Without a
+// keyring the delivery is stored as is.
+func (repository *Repository) sealDelivery(order_uid string, delivery models.Delivery) (storedDelivery, error) {
+	stored := storedDelivery{Delivery: delivery}
+	if repository.keyring == nil {
+		return stored, nil
+	}
+
+	dataKey, wrapped, keyID, err := repository.keyring.NewDataKey()
+	if err != nil {
+		return storedDelivery{}, fmt.Errorf("create data key: %w", err)
+	}
+	stored.KeyID, stored.DataKey = &keyID, wrapped
+	stored.PhoneIndex = repository.keyring.BlindIndex("phone", encryption.NormalizePhone(delivery.Phone))
+	stored.EmailIndex = repository.keyring.BlindIndex("email", encryption.NormalizeEmail(delivery.Email))
+
+	for column, value := range personalFields(&stored.Delivery) {
+		if *value, err = encryption.Encrypt(dataKey, *value, order_uid+"/"+column); err != nil {
+			return storedDelivery{}, fmt.Errorf("encrypt %s: %w", column, err)
+		}
+	}
+	return stored, nil
+}
+
+// openDelivery decrypts a stored delivery. Rows without a key are plaintext.
+func (repository *Repository) openDelivery(stored storedDelivery) (models.Delivery, error) {
+	delivery := stored.Delivery
+	if stored.KeyID == nil {
+		return delivery, nil
+	}
+	if repository.keyring == nil {
+		return models.Delivery{}, fmt.Errorf("delivery of %s is encrypted: %w", stored.OrderUID, ErrEncryptionDisabled)
+	}
+
+	dataKey, err := repository.keyring.UnwrapDataKey(*stored.KeyID, stored.DataKey)
+	if err != nil {
+		return models.Delivery{}, fmt.Errorf("unwrap data key: %w", err)
+	}
+	for column, value := range personalFields(&delivery) {
+		if *value, err = encryption.Decrypt(dataKey, *value, stored.OrderUID+"/"+column); err != nil {
+			return models.Delivery{}, fmt.Errorf("decrypt %s: %w", column, err)
+		}
+	}
+	return delivery, nil
+}
+
+// ReencryptDeliveries encrypts up to batchSize deliveries that are stored in
+// plaintext or under a key other than the primary one and returns how many
+// were rewritten. Rows locked by another instance are skipped.
+func (repository *Repository) ReencryptDeliveries(ctx context.Context, batchSize int) (count int, err error) {
+	defer metrics.ObserveQuery("reencrypt_deliveries", time.Now(), &err)
+	if repository.keyring == nil {
+		return 0, ErrEncryptionDisabled
+	}
+
+	tx, err := repository.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, selectDeliveriesToReencrypt, repository.keyring.PrimaryID(), batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("query: %w", err)
+	}
+	stored, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storedDelivery, error) {
+		return scanDelivery(row)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("collect rows: %w", err)
+	}
+
+	for _, row := range stored {
+		delivery, err := repository.openDelivery(row)
+		if err != nil {
+			return 0, err
+		}
+		sealed, err := repository.sealDelivery(row.OrderUID, delivery)
+		if err != nil {
+			return 0, err
+		}
+		if _, err = tx.Exec(ctx, updateDelivery, sealed.args(row.OrderUID)...); err != nil {
+			return 0, fmt.Errorf("update delivery: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return len(stored), nil
+}
+
+// ReencryptAll re-encrypts deliveries in batches until none are left.
+func (repository *Repository) ReencryptAll(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		count, err := repository.ReencryptDeliveries(ctx, reencryptBatchSize)
+		total += count
+		if err != nil || count < reencryptBatchSize {
+			return total, err
+		}
+	}
+}
+
+// RunReencryption re-encrypts deliveries at start and then periodically, so
+// rows move to the primary key after a rotation, until ctx is done.
+func (repository *Repository) RunReencryption(ctx context.Context) {
+	if repository.keyring == nil {
+		return
+	}
+	ticker := time.NewTicker(reencryptInterval)
+	defer ticker.Stop()
+
+	for {
+		count, err := repository.ReencryptAll(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Re-encryption of deliveries is failed", "error", err)
+		} else if count > 0 {
+			slog.Info("Deliveries re-encrypted", "count", count, "key_id", repository.keyring.PrimaryID())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FindOrdersByContact returns the orders delivered to the phone or email,
+// matched exactly after normalization through the blind indexes.
+func (repository *Repository) FindOrdersByContact(ctx context.Context, phone string, email string) (orders []models.Order, err error) {
+	defer metrics.ObserveQuery("find_orders_by_contact", time.Now(), &err)
+
+	query, kind, value := selectOrdersByPhone, "phone", encryption.NormalizePhone(phone)
+	if phone == "" {
+		query, kind, value = selectOrdersByEmail, "email", encryption.NormalizeEmail(email)
+	}
+	var index []byte
+	if repository.keyring != nil {
+		index = repository.keyring.BlindIndex(kind, value)
+	}
+
+	rows, err := repository.pool.Query(ctx, query, index, value, searchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("collect rows: %w", err)
+	}
+
+	orders = make([]models.Order, 0, len(uids))
+	for _, order_uid := range uids {
+		order, exist, _, err := repository.FindOrderById(ctx, order_uid)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			orders = append(orders, order)
+		}
+	}
+	logger.FromContext(ctx).Debug("Orders found by contact", "by", kind, "count", len(orders))
+	return orders, nil
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"test-task/internal/cache"
+	"test-task/internal/invalidation"
+	models "test-task/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// bus delivers every event to all services, the sender included, the way
+// the Kafka topic does.
+type bus struct {
+	services []*invalidation.Service
+}
+
+func (b *bus) Publish(ctx context.Context, event invalidation.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	for _, s := range b.services {
+		if err := s.HandleMessage(ctx, string(payload)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newTestRepository returns a repository whose database refuses
+// connections, so every reload from it fails. backend may be nil.
+func newTestRepository(t *testing.T, backend cache.Backend) *Repository {
+	t.Helper()
+	pool, err := pgxpool.New(context.Background(), "postgres://test@127.0.0.1:1/test?connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	lru := cache.CreateCache(cacheCapacity)
+	repository := &Repository{pool: pool, cache: lru, lru: lru}
+	if backend != nil {
+		repository.backend = backend
+		repository.cache = cache.NewTiered(lru, backend)
+	}
+	return repository
+}
+
+func cachedVersion(repository *Repository, order_uid string) int {
+	order, exist := repository.cache.Peek(order_uid)
+	if !exist {
+		return 0
+	}
+	return order.Version
+}
+
+func TestInvalidationAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	const uid = "order-1"
+
+	tests := []struct {
+		name     string
+		cachedA  int
+		cachedB  int
+		version  int
+		wantA    int
+		wantB    int
+		evictedB bool
+	}{
+		{name: "older replica is evicted", cachedA: 2, cachedB: 1, version: 2, wantA: 2, evictedB: true},
+		{name: "same version is kept", cachedA: 2, cachedB: 2, version: 2, wantA: 2, wantB: 2},
+		{name: "late event is ignored", cachedA: 2, cachedB: 3, version: 2, wantA: 2, wantB: 3},
+		{name: "unknown version is evicted", cachedA: 2, cachedB: 3, version: 0, wantA: 2, evictedB: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &bus{}
+			repositoryA, repositoryB := newTestRepository(t, nil), newTestRepository(t, nil)
+			serviceA := invalidation.NewService("a", transport, repositoryA)
+			serviceB := invalidation.NewService("b", transport, repositoryB)
+			transport.services = []*invalidation.Service{serviceA, serviceB}
+
+			repositoryA.cache.Add(&models.Order{OrderUID: uid, Version: tt.cachedA})
+			repositoryB.cache.Add(&models.Order{OrderUID: uid, Version: tt.cachedB})
+
+			serviceA.OrderChanged(ctx, uid, tt.version)
+
+			// The sender skips its own event, which would evict its
+			// fresh order.
+			if got := cachedVersion(repositoryA, uid); got != tt.wantA {
+				t.Errorf("sender cached version = %d, want %d", got, tt.wantA)
+			}
+			if tt.evictedB {
+				if repositoryB.cache.Contains(uid) {
+					t.Errorf("replica kept version %d", cachedVersion(repositoryB, uid))
+				}
+				return
+			}
+			if got := cachedVersion(repositoryB, uid); got != tt.wantB {
+				t.Errorf("replica cached version = %d, want %d", got, tt.wantB)
+			}
+		})
+	}
+}
@@ -13,9 +13,10 @@ const (
 			shardkey,
 			sm_id,
 			date_created,
-			oof_shard
+			oof_shard,
+			status
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
 		);`
 	insertDelivery = `
 		INSERT INTO "deliveries" (
@@ -26,9 +27,13 @@ const (
 			city,
 			address,
 			region,
-			email
+			email,
+			key_id,
+			data_key,
+			phone_index,
+			email_index
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8 
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
 		);`
 
 	insertPayment = `
@@ -65,4 +70,163 @@ const (
 		) VALUES (
 			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12 
 		)`
+
+	selectOrder = `
+		SELECT
+			order_uid,
+			track_number,
+			entry,
+			locale,
+			internal_signature,
+			customer_id,
+			delivery_service,
+			shardkey,
+			sm_id,
+			date_created,
+			oof_shard,
+			status,
+			version
+		FROM orders
+		WHERE order_uid = $1;`
+
+	selectStatusForUpdate = `
+		SELECT status FROM orders WHERE order_uid = $1 FOR UPDATE;`
+
+	updateStatus = `
+		UPDATE orders SET status = $2, version = version + 1 WHERE order_uid = $1;`
+
+	selectVersionForUpdate = `
+		SELECT version FROM orders WHERE order_uid = $1 FOR UPDATE;`
+
+	incrementVersion = `
+		UPDATE orders SET version = version + 1 WHERE order_uid = $1;`
+
+	selectDelivery = `
+		SELECT
+			order_uid,
+			name,
+			phone,
+			zip,
+			city,
+			address,
+			region,
+			email,
+			key_id,
+			data_key,
+			phone_index,
+			email_index
+		FROM deliveries
+		WHERE order_uid = $1;`
+
+	updateDelivery = `
+		UPDATE deliveries SET
+			name = $2,
+			phone = $3,
+			zip = $4,
+			city = $5,
+			address = $6,
+			region = $7,
+			email = $8,
+			key_id = $9,
+			data_key = $10,
+			phone_index = $11,
+			email_index = $12
+		WHERE order_uid = $1;`
+
+	// Rows written before encryption was enabled have no key_id.
+	selectDeliveriesToReencrypt = `
+		SELECT
+			order_uid,
+			name,
+			phone,
+			zip,
+			city,
+			address,
+			region,
+			email,
+			key_id,
+			data_key,
+			phone_index,
+			email_index
+		FROM deliveries
+		WHERE key_id IS DISTINCT FROM $1
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED;`
+
+	// Plaintext rows are matched directly until they are re-encrypted.
+	selectOrdersByPhone = `
+		SELECT order_uid FROM deliveries
+		WHERE phone_index = $1
+			OR (key_id IS NULL AND regexp_replace(phone, '\D', '', 'g') = $2)
+		LIMIT $3;`
+
+	selectOrdersByEmail = `
+		SELECT order_uid FROM deliveries
+		WHERE email_index = $1
+			OR (key_id IS NULL AND lower(trim(email)) = $2)
+		LIMIT $3;`
+
+	updateItemStatus = `
+		UPDATE items SET status = $4
+		WHERE order_uid = $1 AND chrt_id = $2 AND rid = $3;`
+
+	insertStatusHistory = `
+		INSERT INTO "order_status_history" (
+			order_uid,
+			from_status,
+			to_status,
+			reason,
+			changed_at
+		) VALUES (
+			$1, $2, $3, $4, $5
+		);`
+
+	selectStatusHistory = `
+		SELECT
+			order_uid,
+			from_status,
+			to_status,
+			reason,
+			changed_at
+		FROM order_status_history
+		WHERE order_uid = $1
+		ORDER BY changed_at, id;`
+
+	selectAuditEntries = `
+		SELECT
+			id,
+			actor,
+			action,
+			order_uid,
+			source,
+			outcome,
+			request_id,
+			details,
+			created_at
+		FROM audit_log
+		WHERE ($1 = '' OR order_uid = $1)
+			AND ($2 = '' OR actor = $2)
+			AND ($3 = 0 OR id < $3)
+		ORDER BY id DESC
+		LIMIT $4;`
+
+	// Orders are paged by (date_created, order_uid) of the last order seen.
+	selectOrderSummaries = `
+		SELECT
+			o.order_uid,
+			o.track_number,
+			o.status,
+			COALESCE(o.delivery_service, '') AS delivery_service,
+			o.date_created,
+			COALESCE(p.amount, 0)::float8 AS amount,
+			COALESCE(p.currency, '') AS currency,
+			(SELECT count(*) FROM items i WHERE i.order_uid = o.order_uid)::int AS item_count
+		FROM orders o
+		LEFT JOIN payments p ON p.order_uid = o.order_uid
+		WHERE ($1 = '' OR o.track_number = $1)
+			AND ($2 = '' OR o.customer_id = $2)
+			AND ($3 = '' OR (o.date_created, o.order_uid) <
+				(SELECT date_created, order_uid FROM orders WHERE order_uid = $3))
+		ORDER BY o.date_created DESC, o.order_uid DESC
+		LIMIT $4;`
 )
@@ -2,56 +2,169 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
 
+	"test-task/internal/breaker"
 	"test-task/internal/cache"
+	"test-task/internal/encryption"
+	"test-task/internal/logger"
+	"test-task/internal/metrics"
 	"test-task/internal/models"
+	"test-task/internal/retry"
+	"test-task/internal/tracing"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Repository struct {
-	pool  *pgxpool.Pool
-	cache cache.Cache
+	pool         *pgxpool.Pool
+	cache        cache.Cache
+	lru          *cache.LRU
+	backend      cache.Backend
+	keyring      *encryption.Keyring
+	warmed       atomic.Bool
+	breaker      *breaker.Breaker
+	snapshotPath string
 }
 
-const cacheCapacity = 10
+// Options configure InitRepository.
+type Options struct {
+	// Backoff is used while waiting for the database at startup.
+	Backoff retry.Backoff
+	// SnapshotPath is the cache snapshot file, empty disables snapshots.
+	SnapshotPath string
+	// Backend is the optional second-level cache shared between instances.
+	// With a Keyring it should be created with the keyring as its Sealer.
+	Backend cache.Backend
+	// Keyring encrypts delivery personal data, nil stores it in plaintext.
+	Keyring *encryption.Keyring
+}
+
+const (
+	cacheCapacity = 10
+	cacheTTL      = 5 * time.Minute
+	negativeTTL   = 30 * time.Second
+
+	breakerThreshold   = 5
+	breakerOpenTimeout = 10 * time.Second
 
-func (repository *Repository) InitRepository(connStr string) error {
+	snapshotInterval = time.Minute
+	snapshotMaxAge   = time.Hour
+)
+
+var (
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrInvalidTransition = errors.New("status transition is not allowed")
+	ErrVersionConflict   = errors.New("order version conflict")
+	ErrItemNotFound      = errors.New("item not found")
+)
+
+// InitRepository creates the pool, waits for the database with backoff and
+// warms the cache, restoring the cache snapshot first if there is one.
+func (repository *Repository) InitRepository(ctx context.Context, connStr string, options Options) error {
 
 	config, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
-		log.Printf("Unable to parse config: %v", err)
+		slog.Error("Unable to parse config", "error", err)
 		return err
 	}
 
-	log.Printf("InitRepository")
+	slog.Info("InitRepository")
+	config.ConnConfig.Tracer = tracing.PgxTracer{}
 
-	repository.pool, err = pgxpool.NewWithConfig(context.Background(), config)
+	repository.pool, err = pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
-		log.Printf("Unable to connect to database: %v", err)
+		slog.Error("Unable to connect to database", "error", err)
 		return err
 	}
 
-	repository.cache = *cache.CreateCache(cacheCapacity)
-
-	orders, err := repository.GetOrders(cacheCapacity)
+	err = retry.Do(ctx, options.Backoff, "postgres", func() error {
+		err := repository.pool.Ping(ctx)
+		// Wrong credentials or a missing database do not fix themselves.
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && (pgErr.Code == "28P01" || pgErr.Code == "28000" || pgErr.Code == "3D000") {
+			return retry.Permanent(err)
+		}
+		return err
+	})
 	if err != nil {
-		log.Printf("Unable to init cache: %v", err)
+		slog.Error("Unable to connect to database", "error", err)
+		repository.pool.Close()
 		return err
 	}
-	for i := 0; i < len(orders); i++ {
-		repository.cache.Add(&orders[i])
+
+	repository.keyring = options.Keyring
+	repository.breaker = breaker.New(breakerThreshold, breakerOpenTimeout)
+	repository.lru = cache.CreateCache(cacheCapacity)
+	repository.lru.SetTTL(cacheTTL)
+	repository.lru.SetNegativeTTL(negativeTTL)
+	if options.Keyring != nil {
+		repository.lru.SetSealer(options.Keyring)
+	}
+	repository.cache = repository.lru
+	if options.Backend != nil {
+		repository.backend = options.Backend
+		repository.cache = cache.NewTiered(repository.lru, options.Backend)
+	}
+
+	repository.snapshotPath = options.SnapshotPath
+	if repository.snapshotPath != "" {
+		restored, err := repository.lru.LoadSnapshot(repository.snapshotPath, snapshotMaxAge)
+		switch {
+		case err == nil:
+			slog.Info("Cache restored from snapshot", "orders", restored)
+		case errors.Is(err, os.ErrNotExist):
+		default:
+			slog.Warn("Cache snapshot is discarded", "path", repository.snapshotPath, "error", err)
+		}
+	}
+
+	if _, err := repository.WarmCache(ctx); err != nil {
+		slog.Error("Unable to init cache", "error", err)
+		return err
 	}
 
 	return nil
 
 }
 
-func (repository *Repository) GetOrders(quantity int) ([]models.Order, error) {
-	ctx := context.Background()
+// WarmCache loads orders from the database into the free cache slots and
+// returns how many were loaded. Cached orders, such as those restored from a
+// snapshot, are kept and not loaded again.
+func (repository *Repository) WarmCache(ctx context.Context) (int, error) {
+	stats := repository.cache.Stats()
+	free := stats.Capacity - stats.Size
+	if free <= 0 {
+		repository.warmed.Store(true)
+		return 0, nil
+	}
+
+	cached := make([]string, 0, stats.Size)
+	for _, key := range repository.cache.Keys() {
+		cached = append(cached, key.OrderUID)
+	}
+	orders, err := repository.GetOrders(ctx, free, cached)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(orders); i++ {
+		repository.cache.Add(&orders[i])
+	}
+	repository.warmed.Store(true)
+	return len(orders), nil
+}
+
+// GetOrders loads up to quantity orders other than the excluded ones.
+func (repository *Repository) GetOrders(ctx context.Context, quantity int, exclude []string) (orders []models.Order, err error) {
+	defer metrics.ObserveQuery("get_orders", time.Now(), &err)
+	log := logger.FromContext(ctx)
 
 	conn, err := repository.pool.Acquire(ctx)
 	if err != nil {
@@ -59,7 +172,11 @@ func (repository *Repository) GetOrders(quantity int) ([]models.Order, error) {
 	}
 	defer conn.Release()
 
-	rows, err := conn.Query(ctx, "SELECT order_uid FROM orders LIMIT $1", quantity)
+	if exclude == nil {
+		// A NULL array would exclude every row.
+		exclude = []string{}
+	}
+	rows, err := conn.Query(ctx, "SELECT order_uid FROM orders WHERE NOT (order_uid = ANY($2)) LIMIT $1", quantity, exclude)
 	if err != nil {
 		return nil, fmt.Errorf("query: %w", err)
 	}
@@ -77,15 +194,14 @@ func (repository *Repository) GetOrders(quantity int) ([]models.Order, error) {
 		return nil, fmt.Errorf("iteration: %w", err)
 	}
 
-	var orders []models.Order
 	for uid := range uidsSet {
-		order, found, err := repository.FindOrderById(uid)
+		order, found, _, err := repository.FindOrderById(ctx, uid)
 		if err != nil {
-			log.Printf("error finding %s: %v", uid, err)
+			log.Error("Finding order is failed", "order_uid", uid, "error", err)
 			continue
 		}
 		if !found {
-			log.Printf("order not found: %s", uid)
+			log.Warn("Order not found", "order_uid", uid)
 			continue
 		}
 		orders = append(orders, order)
@@ -94,149 +210,199 @@ func (repository *Repository) GetOrders(quantity int) ([]models.Order, error) {
 	return orders, nil
 }
 
-func (repository *Repository) InsertToDB(order *models.Order) error {
-	conn, err := repository.pool.Acquire(context.Background())
+func (repository *Repository) InsertToDB(ctx context.Context, order *models.Order) (err error) {
+	defer metrics.ObserveQuery("insert_order", time.Now(), &err)
+	log := logger.FromContext(ctx).With("order_uid", order.OrderUID)
+	conn, err := repository.pool.Acquire(ctx)
 	if err != nil {
-		log.Printf("Unable to get connection from the Pool: %v", err)
+		log.Error("Unable to get connection from the Pool", "error", err)
 		return err
 	}
 	defer conn.Release()
 
-	tx, err := conn.Begin(context.Background())
+	tx, err := conn.Begin(ctx)
 	if err != nil {
-		log.Printf("Error starting transaction: %v", err)
+		log.Error("Error starting transaction", "error", err)
 		return err
 	}
-	defer tx.Rollback(context.Background())
+	defer tx.Rollback(ctx)
 
-	_, err = tx.Exec(context.Background(), insertOrder,
+	if order.Status == "" {
+		order.Status = models.StatusCreated
+	}
+	order.Version = 1
+
+	_, err = tx.Exec(ctx, insertOrder,
 		order.OrderUID, order.TrackNumber, order.Entry,
 		order.Locale, order.InternalSignature, order.CustomerID,
 		order.DeliveryService, order.Shardkey, order.SmID,
-		order.DateCreated, order.OofShard)
+		order.DateCreated, order.OofShard, order.Status)
+	if err != nil {
+		log.Error("Error inserting order", "error", err)
+		return err
+	}
+
+	_, err = tx.Exec(ctx, insertStatusHistory,
+		order.OrderUID, "", order.Status, "", time.Now())
 	if err != nil {
-		log.Printf("Error inserting order: %v", err)
+		log.Error("Error inserting status history", "error", err)
 		return err
 	}
 
-	delivery := &order.Delivery
-	_, err = tx.Exec(context.Background(), insertDelivery,
-		order.OrderUID, delivery.Name, delivery.Phone,
-		delivery.Zip, delivery.City, delivery.Address,
-		delivery.Region, delivery.Email)
+	delivery, err := repository.sealDelivery(order.OrderUID, order.Delivery)
 	if err != nil {
-		log.Printf("Error inserting delivery: %v", err)
+		log.Error("Error encrypting delivery", "error", err)
+		return err
+	}
+	_, err = tx.Exec(ctx, insertDelivery, delivery.args(order.OrderUID)...)
+	if err != nil {
+		log.Error("Error inserting delivery", "error", err)
 		return err
 	}
 
 	payment := &order.Payment
-	_, err = tx.Exec(context.Background(), insertPayment,
+	_, err = tx.Exec(ctx, insertPayment,
 		order.OrderUID, payment.Transaction, payment.RequestID,
 		payment.Currency, payment.Provider, payment.Amount,
 		payment.PaymentDt, payment.Bank, payment.DeliveryCost,
 		payment.GoodsTotal, payment.CustomFee)
 	if err != nil {
-		log.Printf("Error inserting payment: %v", err)
+		log.Error("Error inserting payment", "error", err)
 		return err
 	}
 
 	for i := 0; i < len(order.Items); i++ {
 		item := &order.Items[i]
-		_, err = tx.Exec(context.Background(), insertItem,
+		_, err = tx.Exec(ctx, insertItem,
 			order.OrderUID, item.ChrtID, item.TrackNumber,
 			item.Price, item.Rid, item.Name, item.Sale,
 			item.Size, item.TotalPrice, item.NmID,
 			item.Brand, item.Status,
 		)
 		if err != nil {
-			log.Printf("Error inserting items: %v", err)
+			log.Error("Error inserting items", "error", err)
 			return err
 		}
 	}
 
-	err = tx.Commit(context.Background())
+	err = tx.Commit(ctx)
 	if err != nil {
-		log.Fatalf("Error committing transaction: %v", err)
+		log.Error("Error committing transaction", "error", err)
 		return err
 	}
 
-	log.Println("Insert is completed")
+	// Forget a remembered miss for an order that now exists.
+	repository.cache.Remove(order.OrderUID)
+
+	log.Info("Insert is completed")
 	return nil
 
 }
 
-func (repository *Repository) FindOrderById(order_uid string) (order models.Order, exist bool, err error) {
-	cacheOrder, exist, err := repository.cache.Get(order_uid)
-	if exist {
-		log.Printf("Have found in the cache")
-		return *cacheOrder, true, nil
+// FindOrderById returns the order from the cache or the database. When the
+// database fails or the circuit breaker is open, an expired cache entry is
+// returned with stale set.
+func (repository *Repository) FindOrderById(ctx context.Context, order_uid string) (order models.Order, exist bool, stale bool, err error) {
+	// The load is shared by concurrent callers and must not be cancelled
+	// by the one that started it.
+	loadCtx := context.WithoutCancel(ctx)
+	cached, stale, err := repository.cache.GetOrLoad(order_uid, func() (*models.Order, error) {
+		logger.FromContext(ctx).Debug("Searching in the DB", "order_uid", order_uid)
+
+		var order models.Order
+		var exist bool
+		err := repository.breaker.Execute(func() error {
+			var err error
+			order, exist, err = repository.selectFromDB(loadCtx, order_uid)
+			return err
+		})
+		if err != nil || !exist {
+			return nil, err
+		}
+		return &order, nil
+	})
+	if err != nil || cached == nil {
+		return models.Order{}, false, false, err
 	}
-	log.Printf("Have found in the DB")
-	return repository.selectFromDB(order_uid)
+	return *cached, true, stale, nil
 }
 
-func (repository *Repository) selectFromDB(order_uid string) (order models.Order, exist bool, err error) {
-	exist = true
+func (repository *Repository) selectFromDB(ctx context.Context, order_uid string) (order models.Order, exist bool, err error) {
+	defer metrics.ObserveQuery("select_order", time.Now(), &err)
+	log := logger.FromContext(ctx).With("order_uid", order_uid)
 
-	conn, err := repository.pool.Acquire(context.Background())
+	conn, err := repository.pool.Acquire(ctx)
 	if err != nil {
-		log.Printf("Unable to get connection from the Pool: %v", err)
+		log.Error("Unable to get connection from the Pool", "error", err)
+		return
 	}
 	defer conn.Release()
 
-	tx, err := conn.BeginTx(context.Background(), pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
-		log.Printf("Error starting transaction: %v", err)
+		log.Error("Error starting transaction", "error", err)
+		return
 	}
-	defer tx.Rollback(context.Background())
+	defer tx.Rollback(ctx)
 
-	err = tx.QueryRow(context.Background(), "SELECT * FROM orders WHERE order_uid = $1", order_uid).Scan(
+	return repository.readOrder(ctx, tx, order_uid)
+}
+
+// readOrder reads the order with its delivery, payment and items in tx.
+func (repository *Repository) readOrder(ctx context.Context, tx pgx.Tx, order_uid string) (order models.Order, exist bool, err error) {
+	log := logger.FromContext(ctx).With("order_uid", order_uid)
+	exist = true
+
+	err = tx.QueryRow(ctx, selectOrder, order_uid).Scan(
 		&order.OrderUID, &order.TrackNumber, &order.Entry,
 		&order.Locale, &order.InternalSignature, &order.CustomerID,
 		&order.DeliveryService, &order.Shardkey, &order.SmID,
-		&order.DateCreated, &order.OofShard,
+		&order.DateCreated, &order.OofShard, &order.Status,
+		&order.Version,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			exist = false
 			err = nil
-			log.Printf("Order does not exist = %v\n", order_uid)
+			log.Debug("Order does not exist")
 			return
 		}
-		log.Printf("Error of query: %v", err)
+		log.Error("Error of query", "error", err)
 		return
 	}
 
-	err = tx.QueryRow(context.Background(), "SELECT * FROM deliveries WHERE order_uid = $1", order_uid).Scan(
-		&order.Delivery.OrderUID, &order.Delivery.Name, &order.Delivery.Phone,
-		&order.Delivery.Zip, &order.Delivery.City, &order.Delivery.Address,
-		&order.Delivery.Region, &order.Delivery.Email,
-	)
+	stored, err := scanDelivery(tx.QueryRow(ctx, selectDelivery, order_uid))
 	if err != nil && err != pgx.ErrNoRows {
-		log.Printf("Query of delivery is failed : %v", err)
+		log.Error("Query of delivery is failed", "error", err)
 		return
 	}
+	if err == nil {
+		if order.Delivery, err = repository.openDelivery(stored); err != nil {
+			log.Error("Decrypting delivery is failed", "error", err)
+			return
+		}
+	}
 
-	err = tx.QueryRow(context.Background(), "SELECT * FROM payments WHERE order_uid = $1", order_uid).Scan(
+	err = tx.QueryRow(ctx, "SELECT * FROM payments WHERE order_uid = $1", order_uid).Scan(
 		&order.Payment.OrderUID, &order.Payment.Transaction, &order.Payment.RequestID,
 		&order.Payment.Currency, &order.Payment.Provider, &order.Payment.Amount,
 		&order.Payment.PaymentDt, &order.Payment.Bank, &order.Payment.DeliveryCost,
 		&order.Payment.GoodsTotal, &order.Payment.CustomFee,
 	)
 	if err != nil && err != pgx.ErrNoRows {
-		log.Printf("Query of payment is failed : %v", err)
+		log.Error("Query of payment is failed", "error", err)
 		return
 	}
 
-	rows, err := tx.Query(context.Background(), "SELECT * FROM items WHERE order_uid = $1", order_uid)
+	rows, err := tx.Query(ctx, "SELECT * FROM items WHERE order_uid = $1", order_uid)
 	if err != nil {
-		log.Printf("Query of items is failed: %v", err)
+		log.Error("Query of items is failed", "error", err)
 		return
 	}
 	defer rows.Close()
 	items, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Item])
 	if err != nil {
-		log.Printf("Collecting items is failed: %v", err)
+		log.Error("Collecting items is failed", "error", err)
 		return
 	}
 	order.Items = items
@@ -244,6 +410,264 @@ func (repository *Repository) selectFromDB(order_uid string) (order models.Order
 	return
 }
 
+// ChangeOrderStatus moves the order to the given status if the transition
+// is allowed and records it in the status history.
+func (repository *Repository) ChangeOrderStatus(ctx context.Context, order_uid string, status models.OrderStatus, reason string) (change models.StatusChange, err error) {
+	defer metrics.ObserveQuery("change_status", time.Now(), &err)
+	change = models.StatusChange{
+		OrderUID: order_uid,
+		ToStatus: status,
+		Reason:   reason,
+	}
+
+	tx, err := repository.pool.Begin(ctx)
+	if err != nil {
+		return change, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, selectStatusForUpdate, order_uid).Scan(&change.FromStatus)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return change, ErrOrderNotFound
+		}
+		return change, fmt.Errorf("select status: %w", err)
+	}
+
+	if !change.FromStatus.CanTransitionTo(status) {
+		return change, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, change.FromStatus, status)
+	}
+
+	if _, err = tx.Exec(ctx, updateStatus, order_uid, status); err != nil {
+		return change, fmt.Errorf("update status: %w", err)
+	}
+
+	change.ChangedAt = time.Now()
+	_, err = tx.Exec(ctx, insertStatusHistory,
+		change.OrderUID, change.FromStatus, change.ToStatus,
+		change.Reason, change.ChangedAt)
+	if err != nil {
+		return change, fmt.Errorf("insert status history: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return change, fmt.Errorf("commit: %w", err)
+	}
+
+	repository.refreshCache(ctx, order_uid)
+
+	logger.FromContext(ctx).Info("Order status changed", "order_uid", order_uid,
+		"from", change.FromStatus, "to", change.ToStatus)
+	return change, nil
+}
+
+// UpdateOrder applies a partial update of delivery fields and item statuses.
+// The update is rejected with ErrVersionConflict if the order was changed
+// since the version given in the update.
+func (repository *Repository) UpdateOrder(ctx context.Context, update models.OrderUpdate) (order models.Order, err error) {
+	defer metrics.ObserveQuery("update_order", time.Now(), &err)
+	tx, err := repository.pool.Begin(ctx)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var version int
+	err = tx.QueryRow(ctx, selectVersionForUpdate, update.OrderUID).Scan(&version)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return models.Order{}, ErrOrderNotFound
+		}
+		return models.Order{}, fmt.Errorf("select version: %w", err)
+	}
+	if update.Version != 0 && update.Version != version {
+		return models.Order{}, fmt.Errorf("%w: expected %d, current %d", ErrVersionConflict, update.Version, version)
+	}
+	if update.Empty() {
+		order, _, err = repository.readOrder(ctx, tx, update.OrderUID)
+		return order, err
+	}
+
+	if update.Delivery != nil && !update.Delivery.Empty() {
+		stored, err := scanDelivery(tx.QueryRow(ctx, selectDelivery, update.OrderUID))
+		if err != nil {
+			return models.Order{}, fmt.Errorf("select delivery: %w", err)
+		}
+		delivery, err := repository.openDelivery(stored)
+		if err != nil {
+			return models.Order{}, err
+		}
+
+		update.Delivery.Apply(&delivery)
+
+		sealed, err := repository.sealDelivery(update.OrderUID, delivery)
+		if err != nil {
+			return models.Order{}, err
+		}
+		_, err = tx.Exec(ctx, updateDelivery, sealed.args(update.OrderUID)...)
+		if err != nil {
+			return models.Order{}, fmt.Errorf("update delivery: %w", err)
+		}
+	}
+
+	for _, item := range update.Items {
+		tag, err := tx.Exec(ctx, updateItemStatus, update.OrderUID, item.ChrtID, item.Rid, item.Status)
+		if err != nil {
+			return models.Order{}, fmt.Errorf("update item status: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return models.Order{}, fmt.Errorf("%w: chrt_id=%d rid=%s", ErrItemNotFound, item.ChrtID, item.Rid)
+		}
+	}
+
+	if _, err = tx.Exec(ctx, incrementVersion, update.OrderUID); err != nil {
+		return models.Order{}, fmt.Errorf("increment version: %w", err)
+	}
+
+	// The order is read before the commit, so it is exactly the version this
+	// update wrote and not a later one.
+	order, _, err = repository.readOrder(ctx, tx, update.OrderUID)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("read order: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return models.Order{}, fmt.Errorf("commit: %w", err)
+	}
+	// A concurrent update may have cached a newer version already, the cache
+	// keeps it.
+	repository.cache.Add(&order)
+
+	logger.FromContext(ctx).Info("Order updated", "order_uid", order.OrderUID, "version", order.Version)
+	return order, nil
+}
+
+// refreshCache brings the cache up to date after a local change. A cached
+// order is reloaded; an uncached one, or one that fails to reload, is removed
+// so the shared level does not keep serving the old version.
+func (repository *Repository) refreshCache(ctx context.Context, order_uid string) {
+	if repository.cache.Contains(order_uid) {
+		order, exist, err := repository.selectFromDB(ctx, order_uid)
+		if err == nil && exist {
+			repository.cache.Add(&order)
+			return
+		}
+		logger.FromContext(ctx).Warn("Unable to refresh cached order", "order_uid", order_uid, "error", err)
+	}
+	repository.cache.Remove(order_uid)
+}
+
+// InvalidateOrder applies a change made by another instance. A cached order
+// older than version is dropped from the local level and reloaded on the next
+// read. The shared level is left alone, the instance that made the change
+// has already updated it.
+func (repository *Repository) InvalidateOrder(ctx context.Context, order_uid string, version int) {
+	cached, exist := repository.cache.Peek(order_uid)
+	if exist && version != 0 && cached.Version >= version {
+		return
+	}
+	// Without a cached order this forgets a remembered miss, the order may
+	// have been created.
+	repository.cache.RemoveLocal(order_uid)
+}
+
+func (repository *Repository) GetStatusHistory(ctx context.Context, order_uid string) (history []models.StatusChange, err error) {
+	defer metrics.ObserveQuery("status_history", time.Now(), &err)
+
+	rows, err := repository.pool.Query(ctx, selectStatusHistory, order_uid)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	history, err = pgx.CollectRows(rows, pgx.RowToStructByName[models.StatusChange])
+	if err != nil {
+		return nil, fmt.Errorf("collect rows: %w", err)
+	}
+	return history, nil
+}
+
+// ListOrders returns the summaries of the orders matching the filter,
+// newest first.
+func (repository *Repository) ListOrders(ctx context.Context, filter models.OrderFilter) (orders []models.OrderSummary, err error) {
+	defer metrics.ObserveQuery("list_orders", time.Now(), &err)
+
+	rows, err := repository.pool.Query(ctx, selectOrderSummaries,
+		filter.TrackNumber, filter.CustomerID, filter.After, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	orders, err = pgx.CollectRows(rows, pgx.RowToStructByName[models.OrderSummary])
+	if err != nil {
+		return nil, fmt.Errorf("collect rows: %w", err)
+	}
+	return orders, nil
+}
+
+// Ping checks the database connection and returns the server version.
+func (repository *Repository) Ping(ctx context.Context) (string, error) {
+	if repository.pool == nil {
+		return "", errors.New("database is not connected")
+	}
+	var version string
+	if err := repository.pool.QueryRow(ctx, "SHOW server_version").Scan(&version); err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// CacheWarmed reports whether the cache was filled from the database.
+func (repository *Repository) CacheWarmed() bool {
+	return repository.warmed.Load()
+}
+
+func (repository *Repository) PoolStat() *pgxpool.Stat {
+	if repository.pool == nil {
+		return nil
+	}
+	return repository.pool.Stat()
+}
+
+func (repository *Repository) CacheStats() cache.Stats {
+	return repository.cache.Stats()
+}
+
+// CacheKeys lists the cached orders from the most to the least recently used.
+func (repository *Repository) CacheKeys() []cache.KeyInfo {
+	return repository.cache.Keys()
+}
+
+// EvictOrder drops the order from the cache and reports whether it was cached.
+func (repository *Repository) EvictOrder(order_uid string) bool {
+	exist := repository.cache.Contains(order_uid)
+	repository.cache.Remove(order_uid)
+	return exist
+}
+
+func (repository *Repository) FlushCache() {
+	repository.cache.Flush()
+}
+
+func (repository *Repository) ResizeCache(capacity int) {
+	repository.cache.Resize(capacity)
+}
+
+// RunCacheSnapshots periodically saves the cache snapshot until ctx is done.
+func (repository *Repository) RunCacheSnapshots(ctx context.Context) {
+	if repository.snapshotPath == "" {
+		return
+	}
+	repository.lru.RunSnapshots(ctx, repository.snapshotPath, snapshotInterval)
+}
+
 func (repository *Repository) Close() {
+	if repository.snapshotPath != "" && repository.lru != nil {
+		if err := repository.lru.SaveSnapshot(repository.snapshotPath); err != nil {
+			slog.Error("Unable to save cache snapshot", "path", repository.snapshotPath, "error", err)
+		}
+	}
+	if repository.backend != nil {
+		repository.backend.Close()
+	}
 	repository.pool.Close()
 }
@@ -9,7 +9,9 @@ CREATE TABLE IF NOT EXISTS orders (
     shardkey TEXT,
     sm_id INT NOT NULL,
     date_created TIMESTAMP default NOW(),
-    oof_shard TEXT
+    oof_shard TEXT,
+    status TEXT NOT NULL DEFAULT 'created',
+    version INT NOT NULL DEFAULT 1
 );
 CREATE TABLE IF NOT EXISTS deliveries (
     order_uid TEXT PRIMARY KEY REFERENCES orders(order_uid),
@@ -37,7 +39,7 @@ CREATE TABLE IF NOT EXISTS payments (
 CREATE TABLE IF NOT EXISTS items (
     id SERIAL PRIMARY KEY,
     order_uid TEXT NOT NULL REFERENCES orders(order_uid),
-    chart_id BIGINT,
+    chrt_id BIGINT,
     track_number TEXT,
     price INT,
     rid TEXT,
@@ -48,4 +50,43 @@ CREATE TABLE IF NOT EXISTS items (
     nm_id BIGINT,
     brand TEXT,
     status INT
-);
\ No newline at end of file
+);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'created';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id SERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL REFERENCES orders(order_uid),
+    from_status TEXT NOT NULL DEFAULT '',
+    to_status TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS order_status_history_order_uid_idx ON order_status_history(order_uid);
+INSERT INTO order_status_history (order_uid, to_status, changed_at)
+SELECT o.order_uid, o.status, COALESCE(o.date_created, NOW()) FROM orders o
+WHERE NOT EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_uid = o.order_uid);
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS key_id TEXT;
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS data_key BYTEA;
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS phone_index BYTEA;
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS email_index BYTEA;
+CREATE INDEX IF NOT EXISTS deliveries_phone_index_idx ON deliveries(phone_index);
+CREATE INDEX IF NOT EXISTS deliveries_email_index_idx ON deliveries(email_index);
+CREATE INDEX IF NOT EXISTS deliveries_key_id_idx ON deliveries(key_id);
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    order_uid TEXT NOT NULL,
+    source TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS audit_log_order_uid_idx ON audit_log(order_uid, id);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log(actor, id);
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS details TEXT NOT NULL DEFAULT '';
+CREATE OR REPLACE RULE audit_log_no_update AS ON UPDATE TO audit_log DO INSTEAD NOTHING;
+CREATE OR REPLACE RULE audit_log_no_delete AS ON DELETE TO audit_log DO INSTEAD NOTHING;
+CREATE INDEX IF NOT EXISTS orders_date_created_idx ON orders(date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS orders_track_number_idx ON orders(track_number);
+CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders(customer_id);
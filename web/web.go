@@ -0,0 +1,18 @@
+// Package web holds the pages of the UI, embedded into the binary.
+//
+// For UI work the service can serve the pages from disk instead: start it
+// with WEB_DEV_DIR set to this directory, for example
+//
+//	WEB_DEV_DIR=web go run ./cmd
+//
+// from the repository root. Pages are then read on every request and sent
+// with Cache-Control: no-store, so a browser reload shows an edit without
+// rebuilding the binary. Leave WEB_DEV_DIR unset in production.
+package web
+
+import "embed"
+
+// Files are the UI pages and assets.
+//
+//go:embed *.html
+var Files embed.FS